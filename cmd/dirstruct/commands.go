@@ -0,0 +1,350 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/auroq/directory-structure/pkg/structure"
+)
+
+// scanFlags are the flags shared by every command that scans a directory
+type scanFlags struct {
+	excludeHidden  bool
+	gitIgnore      bool
+	followSymlinks bool
+	workers        int
+}
+
+func addScanFlags(flags *flag.FlagSet) *scanFlags {
+	scan := &scanFlags{}
+	flags.BoolVar(&scan.excludeHidden, "exclude-hidden", false, "leave out entries whose names start with '.'")
+	flags.BoolVar(&scan.gitIgnore, "gitignore", false, "leave out entries ignored by git")
+	flags.BoolVar(&scan.followSymlinks, "follow-symlinks", false, "scan directories that symlinks point to")
+	flags.IntVar(&scan.workers, "workers", 1, "number of directories to read in parallel")
+	return scan
+}
+
+// scan scans the directory at path, capturing basic metadata, to at most maxDepth levels
+func (scan scanFlags) scan(path string, maxDepth int) (*structure.Directory, error) {
+	fullPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	options := structure.ScanOptions{
+		MaxDepth:       maxDepth,
+		FollowSymlinks: scan.followSymlinks,
+		GitIgnore:      scan.gitIgnore,
+		Metadata:       structure.BasicMetadata,
+		Workers:        scan.workers,
+	}
+	if scan.excludeHidden {
+		options.Hidden = structure.ExcludeHidden
+	}
+	return structure.GetDirectoryStructureWithOptions(fullPath, options)
+}
+
+func runPrint(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("print", flag.ContinueOnError)
+	scan := addScanFlags(flags)
+	style := flags.String("style", "tree", "output style: tree, ascii, indent or path")
+	depth := flags.Int("depth", 0, "maximum number of levels to print, 0 for no limit")
+	dirsFirst := flags.Bool("dirs-first", false, "list directories before other entries")
+	natural := flags.Bool("natural", false, "sort numbers in names by value")
+	sizes := flags.Bool("sizes", false, "show file sizes and directory totals")
+	counts := flags.Bool("counts", false, "show the number of entries in each directory")
+	asJSON := flags.Bool("json", false, "write the tree as JSON")
+	if code, ok := parseFlags(flags, args, 1, stderr); !ok {
+		return code
+	}
+	styles := map[string]structure.RenderStyle{
+		"tree":   structure.TreeStyle,
+		"ascii":  structure.ASCIITreeStyle,
+		"indent": structure.IndentStyle,
+		"path":   structure.PathStyle,
+	}
+	renderStyle, ok := styles[*style]
+	if !ok {
+		return fail(stderr, "print", fmt.Errorf("unknown style '%s'", *style))
+	}
+
+	dir, err := scan.scan(flags.Arg(0), *depth)
+	if err != nil {
+		return fail(stderr, "print", err)
+	}
+	if *asJSON {
+		err = writeJSON(stdout, dir)
+	} else {
+		options := structure.RenderOptions{
+			DirectoriesFirst: *dirsFirst,
+			Natural:          *natural,
+			MaxDepth:         *depth,
+			ShowSizes:        *sizes,
+			ShowCounts:       *counts,
+		}
+		err = structure.NewRenderer(renderStyle, options).Render(stdout, dir)
+	}
+	if err != nil {
+		return fail(stderr, "print", err)
+	}
+	return exitOK
+}
+
+// foundEntry is the JSON form of an entry found by the find command
+type foundEntry struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+	Size *int64 `json:"size,omitempty"`
+}
+
+func runFind(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("find", flag.ContinueOnError)
+	scan := addScanFlags(flags)
+	name := flags.String("name", "", "shell pattern the name of an entry must match")
+	entryType := flags.String("type", "", "type of entry: f for files, d for directories, l for symlinks")
+	minSize := flags.Int64("min-size", -1, "minimum size of files in bytes")
+	maxSize := flags.Int64("max-size", -1, "maximum size of files in bytes")
+	limit := flags.Int("limit", 0, "stop after this many entries, 0 for no limit")
+	asJSON := flags.Bool("json", false, "write the entries as JSON")
+	if code, ok := parseFlags(flags, args, 1, stderr); !ok {
+		return code
+	}
+
+	predicates := []structure.Predicate{}
+	if *name != "" {
+		if _, err := filepath.Match(*name, ""); err != nil {
+			return fail(stderr, "find", fmt.Errorf("invalid name pattern '%s': %v", *name, err))
+		}
+		pattern := *name
+		predicates = append(predicates, func(node structure.Node, depth int) bool {
+			matched, _ := filepath.Match(pattern, node.Name())
+			return matched
+		})
+	}
+	if *entryType != "" {
+		nodeTypes := map[string]structure.NodeType{"f": structure.FileNode, "d": structure.DirectoryNode, "l": structure.SymlinkNode}
+		nodeType, ok := nodeTypes[*entryType]
+		if !ok {
+			return fail(stderr, "find", fmt.Errorf("unknown type '%s'", *entryType))
+		}
+		predicates = append(predicates, structure.OfType(nodeType))
+	}
+	if *minSize >= 0 || *maxSize >= 0 {
+		upper := *maxSize
+		if upper < 0 {
+			upper = 1<<63 - 1
+		}
+		predicates = append(predicates, structure.OfType(structure.FileNode), structure.SizeBetween(*minSize, upper))
+	}
+
+	dir, err := scan.scan(flags.Arg(0), 0)
+	if err != nil {
+		return fail(stderr, "find", err)
+	}
+	found := dir.Find(structure.NewQuery(structure.And(predicates...)).Limit(*limit))
+	entries := []foundEntry{}
+	for _, node := range found {
+		entry := foundEntry{Path: node.FullPath(), Type: node.Type().String()}
+		if node.Type() == structure.FileNode && node.Metadata() != nil {
+			size := node.Metadata().Size
+			entry.Size = &size
+		}
+		entries = append(entries, entry)
+	}
+	if *asJSON {
+		err = writeJSON(stdout, entries)
+	} else {
+		for _, entry := range entries {
+			if _, err = fmt.Fprintln(stdout, entry.Path); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return fail(stderr, "find", err)
+	}
+	return exitOK
+}
+
+// treeStats is the JSON form of the output of the stats and snapshot commands
+type treeStats struct {
+	Path        string `json:"path"`
+	Directories int    `json:"directories"`
+	Files       int    `json:"files"`
+	Symlinks    int    `json:"symlinks"`
+	Size        int64  `json:"size"`
+	MaxDepth    int    `json:"maxDepth"`
+	Output      string `json:"output,omitempty"`
+}
+
+func collectStats(dir *structure.Directory, depth int, stats *treeStats) {
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+	stats.Files += len(dir.Files())
+	stats.Symlinks += len(dir.Symlinks())
+	for _, file := range dir.Files() {
+		if file.Metadata() != nil {
+			stats.Size += file.Metadata().Size
+		}
+	}
+	for _, subDir := range dir.SubDirectories() {
+		stats.Directories++
+		collectStats(subDir, depth+1, stats)
+	}
+}
+
+func (stats treeStats) String() string {
+	return fmt.Sprintf("%s: %d directories, %d files, %d symlinks, %d bytes, %d levels deep",
+		stats.Path, stats.Directories, stats.Files, stats.Symlinks, stats.Size, stats.MaxDepth)
+}
+
+func runStats(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	scan := addScanFlags(flags)
+	asJSON := flags.Bool("json", false, "write the statistics as JSON")
+	if code, ok := parseFlags(flags, args, 1, stderr); !ok {
+		return code
+	}
+	dir, err := load(scan, flags.Arg(0), false)
+	if err != nil {
+		return fail(stderr, "stats", err)
+	}
+	stats := treeStats{Path: dir.FullPath()}
+	collectStats(dir, 0, &stats)
+	if *asJSON {
+		err = writeJSON(stdout, stats)
+	} else {
+		_, err = fmt.Fprintln(stdout, stats)
+	}
+	if err != nil {
+		return fail(stderr, "stats", err)
+	}
+	return exitOK
+}
+
+func runSnapshot(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	scan := addScanFlags(flags)
+	output := flags.String("o", "", "file to write the snapshot to")
+	compress := flags.Bool("compress", false, "compress the snapshot")
+	hash := flags.Bool("hash", false, "hash the contents of files so renames can be detected by diff")
+	asJSON := flags.Bool("json", false, "write a summary of the snapshot as JSON")
+	if code, ok := parseFlags(flags, args, 1, stderr); !ok {
+		return code
+	}
+	if *output == "" {
+		return fail(stderr, "snapshot", fmt.Errorf("-o is required"))
+	}
+	dir, err := scan.scan(flags.Arg(0), 0)
+	if err != nil {
+		return fail(stderr, "snapshot", err)
+	}
+	if *hash {
+		if err := dir.ComputeHashes(structure.DefaultHash); err != nil {
+			return fail(stderr, "snapshot", err)
+		}
+	}
+	if err := writeSnapshot(dir, *output, *compress); err != nil {
+		return fail(stderr, "snapshot", err)
+	}
+	stats := treeStats{Path: dir.FullPath(), Output: *output}
+	collectStats(dir, 0, &stats)
+	if *asJSON {
+		err = writeJSON(stdout, stats)
+	} else {
+		_, err = fmt.Fprintf(stdout, "wrote %d directories, %d files and %d symlinks to %s\n",
+			stats.Directories+1, stats.Files, stats.Symlinks, *output)
+	}
+	if err != nil {
+		return fail(stderr, "snapshot", err)
+	}
+	return exitOK
+}
+
+func writeSnapshot(dir *structure.Directory, path string, compress bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := structure.WriteSnapshot(file, dir, structure.SnapshotOptions{Compress: compress}); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// change is the JSON form of a structure.Change
+type change struct {
+	Type    string `json:"type"`
+	Path    string `json:"path"`
+	OldPath string `json:"oldPath,omitempty"`
+}
+
+func runDiff(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	scan := addScanFlags(flags)
+	renames := flags.Bool("renames", false, "report renamed and moved entries, hashing scanned directories")
+	asJSON := flags.Bool("json", false, "write the changes as JSON")
+	if code, ok := parseFlags(flags, args, 2, stderr); !ok {
+		return code
+	}
+	before, err := load(scan, flags.Arg(0), *renames)
+	if err != nil {
+		return fail(stderr, "diff", err)
+	}
+	after, err := load(scan, flags.Arg(1), *renames)
+	if err != nil {
+		return fail(stderr, "diff", err)
+	}
+
+	changes := structure.DiffWithOptions(before, after, structure.DiffOptions{DetectRenames: *renames})
+	if *asJSON {
+		encoded := []change{}
+		for _, c := range changes {
+			encoded = append(encoded, change{Type: c.Type.String(), Path: c.Path, OldPath: c.OldPath})
+		}
+		err = writeJSON(stdout, encoded)
+	} else {
+		for _, c := range changes {
+			if _, err = fmt.Fprintln(stdout, c); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return fail(stderr, "diff", err)
+	}
+	if len(changes) > 0 {
+		return exitDifferences
+	}
+	return exitOK
+}
+
+// load scans the directory at path or reads the snapshot stored there. Scanned
+// directories are hashed if hash is set.
+func load(scan *scanFlags, path string, hash bool) (*structure.Directory, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		dir, err := scan.scan(path, 0)
+		if err == nil && hash {
+			err = dir.ComputeHashes(structure.DefaultHash)
+		}
+		return dir, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	dir, err := structure.ReadSnapshot(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,104 @@
+// Command dirstruct prints, searches, snapshots, compares and summarizes directory trees.
+//
+// Usage:
+//
+//	dirstruct print [flags] PATH
+//	dirstruct find [flags] PATH
+//	dirstruct snapshot [flags] -o FILE PATH
+//	dirstruct diff [flags] OLD NEW
+//	dirstruct stats [flags] PATH
+//
+// Every subcommand accepts -json to write machine-readable output. OLD and NEW can each
+// be a directory, which is scanned, or a snapshot written by the snapshot subcommand.
+// dirstruct exits with 0 on success, 1 if diff found differences and 2 on errors.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK          = 0
+	exitDifferences = 1
+	exitError       = 2
+)
+
+const usage = `usage: dirstruct <command> [flags] [arguments]
+
+commands:
+  print     print a directory tree
+  find      find entries in a directory tree
+  snapshot  save a directory tree to a snapshot file
+  diff      compare directories or snapshots
+  stats     show statistics about a directory tree
+
+Run 'dirstruct <command> -h' for the flags of a command.
+`
+
+// command runs a subcommand with its flags and arguments and returns the exit code
+type command func(args []string, stdout io.Writer, stderr io.Writer) int
+
+var commands = map[string]command{
+	"print":    runPrint,
+	"find":     runFind,
+	"snapshot": runSnapshot,
+	"diff":     runDiff,
+	"stats":    runStats,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the subcommand named by args[0] and returns the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "dirstruct: unknown command '%s'\n%s", args[0], usage)
+		return exitError
+	}
+	return cmd(args[1:], stdout, stderr)
+}
+
+// parseFlags parses args with flags and checks that the number of arguments left is
+// argCount. It returns the exit code to stop with if the command should not run.
+func parseFlags(flags *flag.FlagSet, args []string, argCount int, stderr io.Writer) (int, bool) {
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK, false
+		}
+		return exitError, false
+	}
+	if flags.NArg() != argCount {
+		fmt.Fprintf(stderr, "dirstruct %s: expected %d arguments but got %d\n", flags.Name(), argCount, flags.NArg())
+		flags.Usage()
+		return exitError, false
+	}
+	return exitOK, true
+}
+
+// fail reports err for the command name and returns the exit code for errors
+func fail(stderr io.Writer, name string, err error) int {
+	fmt.Fprintf(stderr, "dirstruct %s: %v\n", name, err)
+	return exitError
+}
+
+// writeJSON writes value to w as indented JSON
+func writeJSON(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/auroq/directory-structure/test/fixtures"
+)
+
+func runCommand(t *testing.T, expectedCode int, args ...string) string {
+	var stdout, stderr bytes.Buffer
+	if code := run(args, &stdout, &stderr); code != expectedCode {
+		t.Fatalf("dirstruct %v exited with %d instead of %d: %s", args, code, expectedCode, stderr.String())
+	}
+	return stdout.String()
+}
+
+func TestRun_Usage(t *testing.T) {
+	runCommand(t, exitError)
+	runCommand(t, exitError, "unknown")
+	runCommand(t, exitOK, "help")
+	runCommand(t, exitOK, "print", "-h")
+	runCommand(t, exitError, "print")
+	runCommand(t, exitError, "print", "-style", "fancy", ".")
+	runCommand(t, exitError, "stats", filepath.Join(os.TempDir(), "does-not-exist"))
+}
+
+func TestRun_Print(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t, filepath.Join("dir1", "file1"), "file2")
+	defer os.RemoveAll(tmpDir)
+
+	expected := strings.Join([]string{tmpDir + "/", "    dir1/", "        file1", "    file2"}, "\n") + "\n"
+	if actual := runCommand(t, exitOK, "print", "-style", "indent", tmpDir); actual != expected {
+		t.Fatalf("print output was incorrect. expected:\n%s\nactual:\n%s", expected, actual)
+	}
+	var tree struct {
+		Name        string
+		Directories []struct{ Name string }
+	}
+	if err := json.Unmarshal([]byte(runCommand(t, exitOK, "print", "-json", tmpDir)), &tree); err != nil {
+		t.Fatal(err)
+	}
+	if tree.Name != filepath.Base(tmpDir) || len(tree.Directories) != 1 || tree.Directories[0].Name != "dir1" {
+		t.Fatalf("print json was incorrect: %+v", tree)
+	}
+}
+
+func TestRun_Find(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t, filepath.Join("dir1", "file1.go"), filepath.Join("dir1", "file2.txt"), "file3.go")
+	defer os.RemoveAll(tmpDir)
+
+	expected := filepath.Join(tmpDir, "dir1", "file1.go") + "\n" + filepath.Join(tmpDir, "file3.go") + "\n"
+	if actual := runCommand(t, exitOK, "find", "-name", "*.go", tmpDir); actual != expected {
+		t.Fatalf("find output was incorrect. expected:\n%s\nactual:\n%s", expected, actual)
+	}
+	var entries []foundEntry
+	if err := json.Unmarshal([]byte(runCommand(t, exitOK, "find", "-type", "d", "-json", tmpDir)), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Path != filepath.Join(tmpDir, "dir1") || entries[0].Type != "directory" {
+		t.Fatalf("find json was incorrect: %+v", entries)
+	}
+}
+
+func TestRun_SnapshotDiffAndStats(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t, filepath.Join("dir1", "file1"), "file2")
+	defer os.RemoveAll(tmpDir)
+	for _, path := range []string{filepath.Join("dir1", "file1"), "file2"} {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, path), []byte(path), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshotDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(snapshotDir)
+	snapshot := filepath.Join(snapshotDir, "tree.snapshot")
+
+	runCommand(t, exitOK, "snapshot", "-compress", "-hash", "-o", snapshot, tmpDir)
+	runCommand(t, exitOK, "diff", snapshot, tmpDir)
+
+	var stats treeStats
+	if err := json.Unmarshal([]byte(runCommand(t, exitOK, "stats", "-json", snapshot)), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Directories != 1 || stats.Files != 2 || stats.Size != int64(len(filepath.Join("dir1", "file1"))+len("file2")) {
+		t.Fatalf("stats were incorrect: %+v", stats)
+	}
+
+	if err := os.Rename(filepath.Join(tmpDir, "file2"), filepath.Join(tmpDir, "dir1", "file2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "file3"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	expected := "moved: file2 -> " + filepath.Join("dir1", "file2") + "\nadded: file3\n"
+	if actual := runCommand(t, exitDifferences, "diff", "-renames", snapshot, tmpDir); actual != expected {
+		t.Fatalf("diff output was incorrect. expected:\n%s\nactual:\n%s", expected, actual)
+	}
+	var changes []change
+	if err := json.Unmarshal([]byte(runCommand(t, exitDifferences, "diff", "-json", snapshot, tmpDir)), &changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) == 0 {
+		t.Fatal("diff json did not contain any changes")
+	}
+}
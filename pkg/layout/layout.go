@@ -0,0 +1,197 @@
+// Package layout checks structure.Directory trees against declarative rules, such as
+// "every directory under services/ must contain a Dockerfile", to enforce the layout
+// conventions of a repository.
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/auroq/directory-structure/pkg/structure"
+)
+
+// Rule is a single layout convention. It applies to every descendant of the root whose
+// path relative to the root matches the Glob pattern Match and whose type is Type.
+// Each of those nodes is a violation if Deny is set. Matched Directories must contain
+// an entry matching each pattern in Require and no entry matching any pattern in Forbid,
+// both relative to the Directory. Patterns use the syntax of structure.Directory.Glob.
+type Rule struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description,omitempty"`
+	Match       string   `json:"match"`
+	Type        string   `json:"type,omitempty"`
+	Require     []string `json:"require,omitempty"`
+	Forbid      []string `json:"forbid,omitempty"`
+	Deny        bool     `json:"deny,omitempty"`
+}
+
+// The values of Rule.Type. Rules without a Type apply to Files, Directories and Symlinks.
+const (
+	FileType      = "file"
+	DirectoryType = "directory"
+	SymlinkType   = "symlink"
+)
+
+// Ruleset is a list of Rules as it is stored in JSON:
+//
+//	{"rules": [
+//	    {"id": "service-files", "match": "services/*", "type": "directory", "require": ["Dockerfile", "README.md"]},
+//	    {"id": "flat-pkg", "match": "pkg/*", "type": "file", "deny": true},
+//	    {"id": "cmd-main", "match": "cmd/*", "type": "directory", "require": ["main.go"]}
+//	]}
+type Ruleset struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Violation is a node that breaks a Rule. Path is relative to the root that was
+// validated and uses '/' as the separator.
+type Violation struct {
+	RuleID  string
+	Path    string
+	Message string
+}
+
+func (violation Violation) String() string {
+	return fmt.Sprintf("%s: %s: %s", violation.RuleID, violation.Path, violation.Message)
+}
+
+// LoadRules reads a Ruleset from JSON and checks that its Rules are valid
+func LoadRules(r io.Reader) (Ruleset, error) {
+	var ruleset Ruleset
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&ruleset); err != nil {
+		return Ruleset{}, err
+	}
+	if err := ruleset.Check(); err != nil {
+		return Ruleset{}, err
+	}
+	return ruleset, nil
+}
+
+// LoadRulesFile reads a Ruleset from the JSON file at path
+func LoadRulesFile(path string) (Ruleset, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Ruleset{}, err
+	}
+	defer file.Close()
+	return LoadRules(file)
+}
+
+// Check returns an error describing the first Rule that is invalid. Rules must have
+// a unique ID, a Match pattern, a known Type, valid patterns and something to check.
+func (ruleset Ruleset) Check() error {
+	ids := map[string]bool{}
+	empty := structure.NewDirectory("", "")
+	for i, rule := range ruleset.Rules {
+		if rule.ID == "" {
+			return fmt.Errorf("rule %d has no id", i)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("rule '%s' is defined twice", rule.ID)
+		}
+		ids[rule.ID] = true
+		if rule.Match == "" {
+			return fmt.Errorf("rule '%s' has no match pattern", rule.ID)
+		}
+		if rule.Type != "" && rule.Type != FileType && rule.Type != DirectoryType && rule.Type != SymlinkType {
+			return fmt.Errorf("rule '%s' has unknown type '%s'", rule.ID, rule.Type)
+		}
+		if !rule.Deny && len(rule.Require) == 0 && len(rule.Forbid) == 0 {
+			return fmt.Errorf("rule '%s' does not require, forbid or deny anything", rule.ID)
+		}
+		for _, pattern := range append(append([]string{rule.Match}, rule.Require...), rule.Forbid...) {
+			if _, err := empty.Glob(pattern); err != nil {
+				return fmt.Errorf("rule '%s' has invalid pattern '%s': %v", rule.ID, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks root against every Rule of the Ruleset. It returns the violations
+// sorted by path and rule ID, and an error if a Rule is invalid.
+func (ruleset Ruleset) Validate(root *structure.Directory) ([]Violation, error) {
+	if err := ruleset.Check(); err != nil {
+		return nil, err
+	}
+	var violations []Violation
+	for _, rule := range ruleset.Rules {
+		ruleViolations, err := rule.validate(root)
+		if err != nil {
+			return nil, err
+		}
+		violations = append(violations, ruleViolations...)
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Path != violations[j].Path {
+			return violations[i].Path < violations[j].Path
+		}
+		return violations[i].RuleID < violations[j].RuleID
+	})
+	return violations, nil
+}
+
+func (rule Rule) validate(root *structure.Directory) ([]Violation, error) {
+	matches, err := root.Glob(rule.Match)
+	if err != nil {
+		return nil, err
+	}
+	var violations []Violation
+	if (rule.Type == "" || rule.Type == FileType) && rule.Deny {
+		for _, file := range matches.Files {
+			violations = append(violations, rule.violation(root, file.FullPath(), "file is not allowed here"))
+		}
+	}
+	if (rule.Type == "" || rule.Type == SymlinkType) && rule.Deny {
+		for _, link := range matches.Symlinks {
+			violations = append(violations, rule.violation(root, link.FullPath(), "symlink is not allowed here"))
+		}
+	}
+	if rule.Type == FileType || rule.Type == SymlinkType {
+		return violations, nil
+	}
+	for _, dir := range matches.Directories {
+		if rule.Deny {
+			violations = append(violations, rule.violation(root, dir.FullPath(), "directory is not allowed here"))
+		}
+		for _, pattern := range rule.Require {
+			found, err := dir.Glob(pattern)
+			if err != nil {
+				return nil, err
+			}
+			if len(found.Directories) == 0 && len(found.Files) == 0 && len(found.Symlinks) == 0 {
+				violations = append(violations, rule.violation(root, dir.FullPath(), "missing "+pattern))
+			}
+		}
+		for _, pattern := range rule.Forbid {
+			found, err := dir.Glob(pattern)
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range found.Files {
+				violations = append(violations, rule.violation(root, file.FullPath(), "forbidden by "+pattern))
+			}
+			for _, subDir := range found.Directories {
+				violations = append(violations, rule.violation(root, subDir.FullPath(), "forbidden by "+pattern))
+			}
+			for _, link := range found.Symlinks {
+				violations = append(violations, rule.violation(root, link.FullPath(), "forbidden by "+pattern))
+			}
+		}
+	}
+	return violations, nil
+}
+
+func (rule Rule) violation(root *structure.Directory, fullPath string, message string) Violation {
+	path, err := filepath.Rel(root.FullPath(), fullPath)
+	if err != nil {
+		path = fullPath
+	}
+	return Violation{RuleID: rule.ID, Path: filepath.ToSlash(path), Message: message}
+}
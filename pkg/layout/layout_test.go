@@ -0,0 +1,127 @@
+package layout
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/auroq/directory-structure/pkg/structure"
+)
+
+const rulesJSON = `{"rules": [
+	{"id": "service-files", "match": "services/*", "type": "directory", "require": ["Dockerfile", "README.md"]},
+	{"id": "flat-pkg", "match": "pkg/*", "type": "file", "deny": true},
+	{"id": "cmd-main", "match": "cmd/*", "type": "directory", "require": ["main.go"]},
+	{"id": "no-vendor", "match": "**/services", "forbid": ["**/vendor"]}
+]}`
+
+func TestRuleset_Validate(t *testing.T) {
+	ruleset, err := LoadRules(strings.NewReader(rulesJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := structure.ParseTree(`
+repo/
+    cmd/
+        server/
+            main.go
+        tool/
+            tool.go
+    pkg/
+        util.go
+        api/
+            api.go
+    services/
+        billing/
+            Dockerfile
+            README.md
+            vendor/
+        users/
+            Dockerfile
+`, "/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	violations, err := ruleset.Validate(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, violation := range violations {
+		actual = append(actual, violation.String())
+	}
+	expected := []string{
+		"cmd-main: cmd/tool: missing main.go",
+		"flat-pkg: pkg/util.go: file is not allowed here",
+		"no-vendor: services/billing/vendor: forbidden by **/vendor",
+		"service-files: services/users: missing README.md",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("violations were incorrect.\nexpected: %v\nactual:   %v", expected, actual)
+	}
+}
+
+func TestRuleset_Validate_Symlinks(t *testing.T) {
+	ruleset, err := LoadRules(strings.NewReader(`{"rules": [
+	{"id": "service-files", "match": "services/*", "type": "directory", "require": ["Dockerfile", "README.md"]},
+	{"id": "flat-pkg", "match": "pkg/*", "deny": true},
+	{"id": "no-links", "match": "cmd/*", "type": "symlink", "deny": true},
+	{"id": "no-vendor", "match": "services/*", "forbid": ["vendor"]}
+]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := structure.ParseTree(`
+repo/
+    cmd/
+        server -> ../services/billing
+        tool/
+    pkg/
+        util -> ../cmd/tool
+    services/
+        billing/
+            Dockerfile -> ../../docker/Dockerfile
+            README.md -> ../../docs/billing.md
+            vendor -> ../../vendor
+`, "/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	violations, err := ruleset.Validate(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, violation := range violations {
+		actual = append(actual, violation.String())
+	}
+	expected := []string{
+		"no-links: cmd/server: symlink is not allowed here",
+		"flat-pkg: pkg/util: symlink is not allowed here",
+		"no-vendor: services/billing/vendor: forbidden by vendor",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("violations were incorrect.\nexpected: %v\nactual:   %v", expected, actual)
+	}
+}
+
+func TestLoadRules_ReturnsErrorForInvalidRules(t *testing.T) {
+	rules := map[string]string{
+		"MissingID":      `{"rules": [{"match": "*", "deny": true}]}`,
+		"DuplicateID":    `{"rules": [{"id": "a", "match": "*", "deny": true}, {"id": "a", "match": "*", "deny": true}]}`,
+		"MissingMatch":   `{"rules": [{"id": "a", "deny": true}]}`,
+		"UnknownType":    `{"rules": [{"id": "a", "match": "*", "type": "socket", "deny": true}]}`,
+		"NothingToCheck": `{"rules": [{"id": "a", "match": "*"}]}`,
+		"BadPattern":     `{"rules": [{"id": "a", "match": "[", "deny": true}]}`,
+		"UnknownField":   `{"rules": [{"id": "a", "match": "*", "deny": true, "requires": ["x"]}]}`,
+	}
+	for name, data := range rules {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadRules(strings.NewReader(data)); err == nil {
+				t.Fatal("error should have been returned but was nil")
+			}
+		})
+	}
+}
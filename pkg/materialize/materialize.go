@@ -0,0 +1,300 @@
+// Package materialize creates the directories, files and symlinks described by a
+// structure.Directory tree on disk. It is kept apart from package structure, which
+// never writes to the filesystem, so that writing has to be opted into explicitly.
+package materialize
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/auroq/directory-structure/pkg/structure"
+)
+
+const (
+	// DefaultDirectoryMode is used for Directories without Metadata
+	DefaultDirectoryMode os.FileMode = 0755
+	// DefaultFileMode is used for Files without Metadata
+	DefaultFileMode os.FileMode = 0644
+)
+
+// ContentProvider returns the contents to write for file.
+// If the returned io.Reader is also an io.Closer, it is closed once it has been read.
+type ContentProvider func(file *structure.File) (io.Reader, error)
+
+// Options controls how Materialize writes a Directory tree
+type Options struct {
+	// DryRun only plans the Actions and checks for conflicts without changing anything on disk
+	DryRun bool
+	// Overwrite replaces files and symlinks that already exist where a File or Symlink
+	// is to be written. Existing directories are always reused, and an existing
+	// directory is never replaced by a File or Symlink.
+	Overwrite bool
+	// ApplyModes sets the permissions of Directories and Files from their Metadata.
+	// The default modes are used for nodes without Metadata.
+	ApplyModes bool
+	// Contents provides the contents of each File. Files are left empty if it is nil.
+	Contents ContentProvider
+}
+
+// Op is the kind of an Action
+type Op int
+
+const (
+	// CreateDirectory creates a directory, or reuses one that exists
+	CreateDirectory Op = iota
+	// CreateFile creates a file
+	CreateFile
+	// CreateSymlink creates a symlink
+	CreateSymlink
+	// ReplaceFile replaces an existing file or symlink with a file
+	ReplaceFile
+	// ReplaceSymlink replaces an existing file or symlink with a symlink
+	ReplaceSymlink
+)
+
+func (op Op) String() string {
+	switch op {
+	case CreateDirectory:
+		return "create directory"
+	case CreateFile:
+		return "create file"
+	case CreateSymlink:
+		return "create symlink"
+	case ReplaceFile:
+		return "replace file"
+	case ReplaceSymlink:
+		return "replace symlink"
+	default:
+		return "unknown"
+	}
+}
+
+// Action is a single change Materialize made, or would make in a dry run.
+// Mode is the permission applied to Directories and Files and Target is the
+// target of a Symlink.
+type Action struct {
+	Op     Op
+	Path   string
+	Mode   os.FileMode
+	Target string
+}
+
+func (action Action) String() string {
+	if action.Target != "" {
+		return fmt.Sprintf("%s: %s -> %s", action.Op, action.Path, action.Target)
+	}
+	return fmt.Sprintf("%s: %s %s", action.Op, action.Path, action.Mode)
+}
+
+// Materialize creates dir and all of its descendants under root, so that dir itself
+// ends up at filepath.Join(root, dir.Name()). Directories reached through a symlink
+// are created as regular directories. Directories that already exist are reused.
+// Existing files and symlinks are only replaced if options.Overwrite is set;
+// otherwise an *os.PathError for which os.IsExist is true is returned.
+// Nothing is written for a node whose name is empty, "." or "..", or contains a
+// separator, since it would end up outside of its parent, or is shared by a sibling
+// of another type. A *structure.PathError for structure.ErrInvalidName or a
+// *structure.ConflictError is returned instead.
+// It returns the Actions taken in the order they were taken, which in a dry run
+// are the Actions that would have been taken.
+func Materialize(dir *structure.Directory, root string, options Options) ([]Action, error) {
+	m := materializer{options: options}
+	diskPath := root
+	if dir.Name() != "" {
+		var err error
+		if diskPath, err = childPath(root, dir.Name()); err != nil {
+			return nil, err
+		}
+	}
+	err := m.directory(dir, diskPath)
+	return m.actions, err
+}
+
+type materializer struct {
+	options Options
+	actions []Action
+}
+
+// directory creates dir at diskPath followed by its children. Its mode is only applied
+// once its children have been written, so read-only directories can be created too.
+func (m *materializer) directory(dir *structure.Directory, diskPath string) error {
+	mode := m.mode(dir.Metadata(), DefaultDirectoryMode)
+	info, err := os.Lstat(diskPath)
+	switch {
+	case err == nil && !info.IsDir():
+		return &os.PathError{Op: "materialize", Path: diskPath, Err: os.ErrExist}
+	case err != nil && !os.IsNotExist(err):
+		return err
+	}
+	m.actions = append(m.actions, Action{Op: CreateDirectory, Path: diskPath, Mode: mode})
+	if !m.options.DryRun && err != nil {
+		if err := os.Mkdir(diskPath, 0700); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedNames(dir) {
+		childPath, err := childPath(diskPath, name)
+		if err != nil {
+			return err
+		}
+		if types := childTypes(dir, name); len(types) > 1 {
+			return &structure.ConflictError{Path: childPath, Existing: types[0], Added: types[1]}
+		}
+		if subDir := dir.SubDirectory(name); subDir != nil {
+			err = m.directory(subDir, childPath)
+		} else if file := dir.File(name); file != nil {
+			err = m.file(file, childPath)
+		} else {
+			err = m.symlink(dir.Symlink(name), childPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.options.DryRun || !m.options.ApplyModes && info != nil {
+		return nil
+	}
+	return os.Chmod(diskPath, mode)
+}
+
+func (m *materializer) file(file *structure.File, diskPath string) error {
+	mode := m.mode(file.Metadata(), DefaultFileMode)
+	op, err := m.replace(diskPath, CreateFile, ReplaceFile)
+	if err != nil {
+		return err
+	}
+	m.actions = append(m.actions, Action{Op: op, Path: diskPath, Mode: mode})
+	if m.options.DryRun {
+		return nil
+	}
+	if op == ReplaceFile {
+		if err := os.Remove(diskPath); err != nil {
+			return err
+		}
+	}
+	out, err := os.OpenFile(diskPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if err := m.writeContents(file, out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// the mode passed to OpenFile is reduced by the umask
+	return os.Chmod(diskPath, mode)
+}
+
+func (m *materializer) symlink(link *structure.Symlink, diskPath string) error {
+	op, err := m.replace(diskPath, CreateSymlink, ReplaceSymlink)
+	if err != nil {
+		return err
+	}
+	m.actions = append(m.actions, Action{Op: op, Path: diskPath, Target: link.Target()})
+	if m.options.DryRun {
+		return nil
+	}
+	if op == ReplaceSymlink {
+		if err := os.Remove(diskPath); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(link.Target(), diskPath)
+}
+
+// replace determines if diskPath is created or replaced. It returns an error if
+// something already exists there that must not be replaced.
+func (m *materializer) replace(diskPath string, create Op, replace Op) (Op, error) {
+	info, err := os.Lstat(diskPath)
+	switch {
+	case os.IsNotExist(err):
+		return create, nil
+	case err != nil:
+		return create, err
+	case info.IsDir() || !m.options.Overwrite:
+		return create, &os.PathError{Op: "materialize", Path: diskPath, Err: os.ErrExist}
+	default:
+		return replace, nil
+	}
+}
+
+func (m *materializer) writeContents(file *structure.File, out io.Writer) error {
+	if m.options.Contents == nil {
+		return nil
+	}
+	contents, err := m.options.Contents(file)
+	if err != nil {
+		return err
+	}
+	if contents == nil {
+		return nil
+	}
+	if closer, ok := contents.(io.Closer); ok {
+		defer closer.Close()
+	}
+	_, err = io.Copy(out, contents)
+	return err
+}
+
+// childPath returns the path of the child called name inside parent, or an error if
+// name is not a single element of a path and the child would end up elsewhere
+func childPath(parent string, name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/"+string(os.PathSeparator)) {
+		return "", &structure.PathError{Op: "materialize", Path: filepath.Join(parent, name), Err: structure.ErrInvalidName}
+	}
+	return structure.SafeJoin(parent, name)
+}
+
+// mode returns the permissions to apply from metadata, or fallback if there
+// are none or they should not be applied
+func (m *materializer) mode(metadata *structure.Metadata, fallback os.FileMode) os.FileMode {
+	if m.options.ApplyModes && metadata != nil {
+		return metadata.Mode.Perm()
+	}
+	return fallback
+}
+
+// sortedNames returns the names of all children of dir in order. A name shared by
+// children of different types is only listed once.
+func sortedNames(dir *structure.Directory) []string {
+	seen := map[string]bool{}
+	for name := range dir.SubDirectories() {
+		seen[name] = true
+	}
+	for name := range dir.Files() {
+		seen[name] = true
+	}
+	for name := range dir.Symlinks() {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// childTypes returns the types of the children of dir called name. A path on disk can
+// only hold one of them.
+func childTypes(dir *structure.Directory, name string) []structure.NodeType {
+	var types []structure.NodeType
+	if dir.File(name) != nil {
+		types = append(types, structure.FileNode)
+	}
+	if dir.SubDirectory(name) != nil {
+		types = append(types, structure.DirectoryNode)
+	}
+	if dir.Symlink(name) != nil {
+		types = append(types, structure.SymlinkNode)
+	}
+	return types
+}
@@ -0,0 +1,88 @@
+package structure
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ConflictPolicy decides what AddDirectoryWithOptions, AddFileWithOptions and
+// AddSymlinkWithOptions do when an entry of the same type already exists
+type ConflictPolicy int
+
+const (
+	// ReplaceExisting replaces the existing entry, discarding a replaced Directory's subtree.
+	// It is the policy of AddDirectory, AddFile and AddSymlink.
+	ReplaceExisting ConflictPolicy = iota
+	// KeepExisting keeps the existing entry and returns it, like mkdir -p
+	KeepExisting
+	// ErrorOnConflict returns a *ConflictError
+	ErrorOnConflict
+)
+
+// AddOptions controls how entries are added to a Directory tree
+type AddOptions struct {
+	// Conflict decides what happens when an entry of the same type already exists
+	Conflict ConflictPolicy
+	// Strict rejects an entry whose name is taken by an entry of another type, including
+	// Directories that would have to be created where a File or Symlink exists, like a
+	// real filesystem would. Otherwise a File, a Directory and a Symlink may share a name.
+	Strict bool
+}
+
+// ConflictError is returned when an entry cannot be added because its name is taken.
+// It matches ErrNameConflict with errors.Is.
+// Path is the full path of the entry, Existing the type of the entry that has the
+// name and Added the type of the entry that was to be added.
+type ConflictError struct {
+	Path     string
+	Existing NodeType
+	Added    NodeType
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("cannot add %s '%s': a %s with that name already exists", err.Added, err.Path, err.Existing)
+}
+
+// Is reports whether target is ErrNameConflict
+func (err *ConflictError) Is(target error) bool { return target == ErrNameConflict }
+
+// parentFor returns the Directory below the current Directory that the names in
+// pathSlice lead to, creating any Directories that do not exist yet
+func (dir *Directory) parentFor(pathSlice []string, options AddOptions) (*Directory, error) {
+	return dir.createPath(pathSlice, options.Strict)
+}
+
+// resolve checks if an entry of nodeType can be added to parent as name. It returns
+// the existing entry if it is to be kept, or a *ConflictError if it cannot be added.
+func (options AddOptions) resolve(parent *Directory, name string, nodeType NodeType) (Node, error) {
+	existing := map[NodeType]Node{}
+	if subDir := parent.SubDirectory(name); subDir != nil {
+		existing[DirectoryNode] = subDir
+	}
+	if file := parent.File(name); file != nil {
+		existing[FileNode] = file
+	}
+	if link := parent.Symlink(name); link != nil {
+		existing[SymlinkNode] = link
+	}
+	fullPath := filepath.Join(parent.FullPath(), name)
+	if options.Strict {
+		for _, existingType := range []NodeType{FileNode, DirectoryNode, SymlinkNode} {
+			if _, ok := existing[existingType]; ok && existingType != nodeType {
+				return nil, &ConflictError{Path: fullPath, Existing: existingType, Added: nodeType}
+			}
+		}
+	}
+	node, ok := existing[nodeType]
+	if !ok {
+		return nil, nil
+	}
+	switch options.Conflict {
+	case KeepExisting:
+		return node, nil
+	case ErrorOnConflict:
+		return nil, &ConflictError{Path: fullPath, Existing: nodeType, Added: nodeType}
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,117 @@
+package structure
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectory_AddDirectory_ReplacesExistingByDefault(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	root := dir.FullPath()
+	if _, err := dir.AddFile(filepath.Join(root, "sub1", "file1")); err != nil {
+		t.Fatal(err)
+	}
+	replaced, err := dir.AddDirectory(filepath.Join(root, "sub1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.File("file1") != nil || dir.SubDirectory("sub1") != replaced {
+		t.Fatal("existing directory was not replaced")
+	}
+}
+
+func TestDirectory_AddDirectoryWithOptions_KeepExisting(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	root := dir.FullPath()
+	file, err := dir.AddFile(filepath.Join(root, "sub1", "file1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := dir.SubDirectory("sub1")
+	kept, err := dir.AddDirectoryWithOptions(filepath.Join(root, "sub1"), AddOptions{Conflict: KeepExisting})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept != existing || kept.File("file1") != file {
+		t.Fatal("existing directory was not kept")
+	}
+	keptFile, err := dir.AddFileWithOptions(filepath.Join(root, "sub1", "file1"), AddOptions{Conflict: KeepExisting})
+	if err != nil || keptFile != file {
+		t.Fatalf("existing file was not kept: %v", err)
+	}
+}
+
+func TestDirectory_AddWithOptions_ErrorOnConflict(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	root := dir.FullPath()
+	options := AddOptions{Conflict: ErrorOnConflict}
+	if _, err := dir.AddDirectoryWithOptions(filepath.Join(root, "sub1"), options); err != nil {
+		t.Fatal(err)
+	}
+	_, err := dir.AddDirectoryWithOptions(filepath.Join(root, "sub1"), options)
+	conflict, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("expected a *ConflictError but got %v", err)
+	}
+	if conflict.Path != filepath.Join(root, "sub1") || conflict.Existing != DirectoryNode || conflict.Added != DirectoryNode {
+		t.Fatalf("conflict error was incorrect: %+v", conflict)
+	}
+	if _, err := dir.AddSymlinkWithOptions(filepath.Join(root, "link"), "target", options); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.AddSymlinkWithOptions(filepath.Join(root, "link"), "other", options); err == nil {
+		t.Fatal("adding an existing symlink should have returned an error")
+	}
+}
+
+func TestDirectory_AddWithOptions_Strict(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	root := dir.FullPath()
+	if _, err := dir.AddFile(filepath.Join(root, "name")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.AddDirectory(filepath.Join(root, "name")); err != nil {
+		t.Fatalf("a file and a directory should be able to share a name when not strict: %v", err)
+	}
+
+	strict := AddOptions{Conflict: KeepExisting, Strict: true}
+	dir = NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	if _, err := dir.AddFileWithOptions(filepath.Join(root, "name"), strict); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]func() error{
+		"Directory": func() error {
+			_, err := dir.AddDirectoryWithOptions(filepath.Join(root, "name"), strict)
+			return err
+		},
+		"Symlink": func() error {
+			_, err := dir.AddSymlinkWithOptions(filepath.Join(root, "name"), "target", strict)
+			return err
+		},
+		"IntermediateDirectory": func() error {
+			_, err := dir.AddFileWithOptions(filepath.Join(root, "name", "file"), strict)
+			return err
+		},
+	}
+	for name, add := range tests {
+		t.Run(name, func(t *testing.T) {
+			conflict, ok := add().(*ConflictError)
+			if !ok || conflict.Existing != FileNode || conflict.Path != filepath.Join(root, "name") {
+				t.Fatalf("expected a *ConflictError for the existing file but got %v", conflict)
+			}
+		})
+	}
+	if dir.SubDirectory("name") != nil || dir.Symlink("name") != nil {
+		t.Fatal("conflicting entries were added")
+	}
+}
+
+func TestDirectory_AddWithOptions_StrictReportsExistingTypesInOrder(t *testing.T) {
+	dir := fixtureTree(filepath.Join(osRoot(), "tmp"), "dir1/\n    name/\n    name\n")
+	for i := 0; i < 20; i++ {
+		_, err := dir.AddSymlinkWithOptions(filepath.Join(dir.FullPath(), "name"), "target", AddOptions{Strict: true})
+		if conflict, ok := err.(*ConflictError); !ok || conflict.Existing != FileNode {
+			t.Fatalf("expected a *ConflictError for the existing file but got %v", err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package structure
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectory_MapFnDepthContext_StopsWhenCancelled(t *testing.T) {
+	for _, tt := range FindTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			visited := 0
+			err := tt.dir.MapFnDepthContext(ctx, func(directory *Directory) error {
+				visited++
+				cancel()
+				return nil
+			})
+			if err != context.Canceled {
+				t.Fatalf("error was incorrect. expected: %v actual: %v", context.Canceled, err)
+			}
+			if visited != 1 {
+				t.Fatalf("function was called %d times after the context was cancelled", visited-1)
+			}
+		})
+	}
+}
+
+func TestDirectory_MapFnBreadthContext_StopsWhenCancelled(t *testing.T) {
+	for _, tt := range FindTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			visited := 0
+			err := tt.dir.MapFnBreadthContext(ctx, func(directory *Directory) error {
+				visited++
+				cancel()
+				return nil
+			})
+			if err != context.Canceled {
+				t.Fatalf("error was incorrect. expected: %v actual: %v", context.Canceled, err)
+			}
+			if visited != 1 {
+				t.Fatalf("function was called %d times after the context was cancelled", visited-1)
+			}
+		})
+	}
+}
+
+func TestDirectory_FindContext_ReturnsPartialMatches(t *testing.T) {
+	dir := metadataTree(filepath.Join(osRoot(), "tmp"), queryMetadata)
+	ctx, cancel := context.WithCancel(context.Background())
+	matches, err := dir.FindContext(ctx, NewQuery(func(node Node, depth int) bool {
+		cancel()
+		return true
+	}))
+	if err != context.Canceled {
+		t.Fatalf("error was incorrect. expected: %v actual: %v", context.Canceled, err)
+	}
+	if len(matches) == 0 || len(matches) == len(dir.Find(NewQuery(nil))) {
+		t.Fatalf("a partial result was expected but found %d matches", len(matches))
+	}
+}
+
+func TestDirectory_FindContext_LimitIsNotAnError(t *testing.T) {
+	dir := metadataTree(filepath.Join(osRoot(), "tmp"), queryMetadata)
+	matches, err := dir.FindContext(context.Background(), NewQuery(nil).Limit(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 match but found %d", len(matches))
+	}
+}
+
+func TestDirectory_GlobContext_ReturnsErrorWhenCancelled(t *testing.T) {
+	dir := NewDirectory("root", filepath.Join(osRoot(), "tmp"))
+	if _, err := dir.AddFile(filepath.Join(osRoot(), "tmp", "root", "file")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := dir.GlobContext(ctx, "*"); err != context.Canceled {
+		t.Fatalf("error was incorrect. expected: %v actual: %v", context.Canceled, err)
+	}
+}
@@ -3,6 +3,7 @@ package structure
 type Descendants struct {
 	Directories []*Directory
 	Files       []*File
+	Symlinks    []*Symlink
 }
 
 // ContainsDirectory determines whether Descendants contains a Directory
@@ -27,23 +28,35 @@ func (desc Descendants) ContainsFile(file *File) bool {
 	return false
 }
 
+// ContainsSymlink determines whether Descendants contains a Symlink
+// It returns true or false accordingly
+func (desc Descendants) ContainsSymlink(link *Symlink) bool {
+	for _, l := range desc.Symlinks {
+		if l.Equals(link) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetAllDescendants walks through the given directory builds a structure of its descendants
-// It returns a Descendants which has two lists: one for all the Directory descendants and
-// one for all the File descendants
+// It returns a Descendants which has three lists: one for all the Directory descendants,
+// one for all the File descendants and one for all the Symlink descendants
 func (dir Directory) GetAllDescendants() Descendants {
-	descDirs, descFiles := dir.getDescendants()
-	return Descendants{Directories: descDirs, Files: descFiles}
+	var desc Descendants
+	dir.getDescendants(&desc)
+	return desc
 }
 
-func (dir Directory) getDescendants() (dirs []*Directory, files []*File) {
+func (dir Directory) getDescendants(desc *Descendants) {
 	for _, file := range dir.files {
-		files = append(files, file)
+		desc.Files = append(desc.Files, file)
+	}
+	for _, link := range dir.symlinks {
+		desc.Symlinks = append(desc.Symlinks, link)
 	}
 	for _, subdir := range dir.subDirectories {
-		dirs = append(dirs, subdir)
-		descDirs, descFiles := subdir.getDescendants()
-		dirs = append(dirs, descDirs...)
-		files = append(files, descFiles...)
+		desc.Directories = append(desc.Directories, subdir)
+		subdir.getDescendants(desc)
 	}
-	return
 }
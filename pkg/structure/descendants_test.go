@@ -15,6 +15,7 @@ func TestDescendants_ContainsDirectory_TrueWhenContainsDirectory(t *testing.T) {
 	desc := Descendants{
 		[]*Directory{dir1, dir2, subdir1},
 		[]*File{&file1, &file2, &file3},
+		nil,
 	}
 	if !desc.ContainsDirectory(dir2) {
 		t.Fatal("directory was not found in descendants")
@@ -31,6 +32,7 @@ func TestDescendants_ContainsDirectory_FalseWhenNotContainsDirectory(t *testing.
 	desc := Descendants{
 		[]*Directory{dir1, subdir1},
 		[]*File{&file1, &file2, &file3},
+		nil,
 	}
 	if desc.ContainsDirectory(dir2) {
 		t.Fatal("directory was found in descendants but should not have been")
@@ -47,6 +49,7 @@ func TestDescendants_ContainsFile_TrueWhenContainsFile(t *testing.T) {
 	desc := Descendants{
 		[]*Directory{dir1, dir2, subdir1},
 		[]*File{&file1, &file2, &file3},
+		nil,
 	}
 	if !desc.ContainsFile(&file2) {
 		t.Fatal("file was not found in descendants")
@@ -63,6 +66,7 @@ func TestDescendants_ContainsFile_FalseWhenNotContainsFile(t *testing.T) {
 	desc := Descendants{
 		[]*Directory{dir1, dir2, subdir1},
 		[]*File{&file1, &file3},
+		nil,
 	}
 	if desc.ContainsFile(&file2) {
 		t.Fatal("file was found in descendants but should not have been")
@@ -125,3 +129,24 @@ func TestDirectory_GetAllDescendants(t *testing.T) {
 		}
 	}
 }
+
+func TestDirectory_GetAllDescendants_IncludesSymlinks(t *testing.T) {
+	dir := NewDirectory("dir", filepath.Join(osRoot(), "tmp"))
+	link1, err := dir.AddSymlink(filepath.Join(osRoot(), "tmp", "dir", "link1"), "target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link2, err := dir.AddSymlink(filepath.Join(osRoot(), "tmp", "dir", "subdir1", "link2"), "target")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descendants := dir.GetAllDescendants()
+
+	if len(descendants.Symlinks) != 2 || !descendants.ContainsSymlink(link1) || !descendants.ContainsSymlink(link2) {
+		t.Fatalf("symlinks were not found in descendants: %v", descendants.Symlinks)
+	}
+	if len(descendants.Files) != 0 {
+		t.Fatal("symlinks were counted as files")
+	}
+}
@@ -0,0 +1,216 @@
+package structure
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// ChangeType describes how an entry differs between two Directory trees
+type ChangeType int
+
+const (
+	// Added entries only exist in the second tree
+	Added ChangeType = iota
+	// Removed entries only exist in the first tree
+	Removed
+	// TypeChanged entries are a different NodeType in each tree
+	TypeChanged
+	// Modified entries exist in both trees but their digests or Metadata differ
+	Modified
+	// Renamed entries were given a new name in the same parent Directory
+	Renamed
+	// Moved entries were moved to a different parent Directory
+	Moved
+)
+
+func (changeType ChangeType) String() string {
+	switch changeType {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case TypeChanged:
+		return "type changed"
+	case Modified:
+		return "modified"
+	case Renamed:
+		return "renamed"
+	case Moved:
+		return "moved"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a single difference between two Directory trees.
+// Path is relative to the roots of the trees being compared.
+// Before is the entry in the first tree and is nil for Added entries.
+// After is the entry in the second tree and is nil for Removed entries.
+// For Renamed and Moved entries, Path is the new path, OldPath is the path
+// in the first tree and Similarity is the fraction of Files the two share.
+type Change struct {
+	Type       ChangeType
+	Path       string
+	OldPath    string
+	Similarity float64
+	Before     Node
+	After      Node
+}
+
+func (change Change) String() string {
+	if change.Type == Renamed || change.Type == Moved {
+		return fmt.Sprintf("%s: %s -> %s", change.Type, change.OldPath, change.Path)
+	}
+	return fmt.Sprintf("%s: %s", change.Type, change.Path)
+}
+
+// DiffOptions controls how DiffWithOptions compares two Directory trees
+type DiffOptions struct {
+	// DetectRenames pairs Removed entries with Added entries that have the same
+	// contents and reports each pair as a single Renamed or Moved change.
+	// Files are paired by digest, so the trees should have been hashed.
+	DetectRenames bool
+	// SameFilesystem also pairs Files by inode and device. It should only be set
+	// when both trees were scanned with Metadata from the same filesystem.
+	SameFilesystem bool
+	// SimilarityThreshold is the fraction of Files a removed and an added Directory
+	// must share for them to be paired. Zero means only Directories with identical
+	// contents are paired. Differences inside a paired Directory are reported
+	// relative to its new path.
+	SimilarityThreshold float64
+}
+
+// Diff compares the tree rooted at before with the tree rooted at after and returns
+// every entry that differs between them, sorted by path. The names of the roots
+// themselves are not compared, so trees scanned at different locations can be diffed.
+// Entries are only reported as Modified if both sides have digests or Metadata:
+// Files are modified if their digests, size, mode, modification time or owner differ,
+// Directories only if their mode or owner differ. Symlinks are also modified
+// whenever their targets differ. Subtrees whose Merkle digests
+// match are skipped entirely. When an entry is replaced by one of another NodeType, only
+// a single TypeChanged entry is reported for it. Where entries of several NodeTypes share
+// a name, each is compared with the entry of the same NodeType on the other side.
+func Diff(before, after *Directory) []Change {
+	return DiffWithOptions(before, after, DiffOptions{})
+}
+
+// DiffWithOptions works like Diff but allows renames and moves to be detected
+// as described by options
+func DiffWithOptions(before, after *Directory, options DiffOptions) []Change {
+	var changes []Change
+	diffDirectories(before, after, "", &changes)
+	if options.DetectRenames {
+		changes = detectRenames(changes, options)
+	}
+	sortChanges(changes)
+	return changes
+}
+
+func diffDirectories(before, after *Directory, relPath string, changes *[]Change) {
+	if before.ContentEquals(after) {
+		return
+	}
+	beforeChildren, afterChildren := before.childrenByName(), after.childrenByName()
+	for name, beforeNodes := range beforeChildren {
+		path := filepath.Join(relPath, name)
+		afterNodes := afterChildren[name]
+		if len(beforeNodes) == 1 && len(afterNodes) == 1 && beforeNodes[0].Type() != afterNodes[0].Type() {
+			*changes = append(*changes, Change{Type: TypeChanged, Path: path, Before: beforeNodes[0], After: afterNodes[0]})
+			continue
+		}
+		for _, beforeNode := range beforeNodes {
+			afterNode := childOfType(afterNodes, beforeNode.Type())
+			switch {
+			case afterNode == nil:
+				*changes = append(*changes, Change{Type: Removed, Path: path, Before: beforeNode})
+				if subDir, ok := beforeNode.(*Directory); ok {
+					descendantChanges(subDir, path, Removed, changes)
+				}
+			case beforeNode.Type() == DirectoryNode:
+				if directoryModified(beforeNode.(*Directory), afterNode.(*Directory)) {
+					*changes = append(*changes, Change{Type: Modified, Path: path, Before: beforeNode, After: afterNode})
+				}
+				diffDirectories(beforeNode.(*Directory), afterNode.(*Directory), path, changes)
+			case nodeModified(beforeNode, afterNode):
+				*changes = append(*changes, Change{Type: Modified, Path: path, Before: beforeNode, After: afterNode})
+			}
+		}
+		for _, afterNode := range afterNodes {
+			if childOfType(beforeNodes, afterNode.Type()) == nil {
+				addedChange(afterNode, path, changes)
+			}
+		}
+	}
+	for name, afterNodes := range afterChildren {
+		if _, ok := beforeChildren[name]; ok {
+			continue
+		}
+		for _, afterNode := range afterNodes {
+			addedChange(afterNode, filepath.Join(relPath, name), changes)
+		}
+	}
+}
+
+// addedChange reports node at path as Added along with all of its descendants
+func addedChange(node Node, path string, changes *[]Change) {
+	*changes = append(*changes, Change{Type: Added, Path: path, After: node})
+	if subDir, ok := node.(*Directory); ok {
+		descendantChanges(subDir, path, Added, changes)
+	}
+}
+
+func descendantChanges(dir *Directory, relPath string, changeType ChangeType, changes *[]Change) {
+	for _, node := range dir.sortedChildren() {
+		path := filepath.Join(relPath, node.Name())
+		change := Change{Type: changeType, Path: path}
+		if changeType == Removed {
+			change.Before = node
+		} else {
+			change.After = node
+		}
+		*changes = append(*changes, change)
+		if subDir, ok := node.(*Directory); ok {
+			descendantChanges(subDir, path, changeType, changes)
+		}
+	}
+}
+
+// nodeModified compares two Files or two Symlinks. Symlinks are also
+// modified if their targets differ.
+func nodeModified(before, after Node) bool {
+	if beforeLink, ok := before.(*Symlink); ok && beforeLink.target != after.(*Symlink).target {
+		return true
+	}
+	if before.Digest() != nil && after.Digest() != nil && !bytes.Equal(before.Digest(), after.Digest()) {
+		return true
+	}
+	beforeMetadata, afterMetadata := before.Metadata(), after.Metadata()
+	if beforeMetadata != nil && afterMetadata != nil {
+		return beforeMetadata.Size != afterMetadata.Size ||
+			beforeMetadata.Mode != afterMetadata.Mode ||
+			!beforeMetadata.ModTime.Equal(afterMetadata.ModTime) ||
+			beforeMetadata.Uid != afterMetadata.Uid ||
+			beforeMetadata.Gid != afterMetadata.Gid
+	}
+	return false
+}
+
+func directoryModified(before, after *Directory) bool {
+	if before.metadata != nil && after.metadata != nil {
+		return before.metadata.Mode != after.metadata.Mode ||
+			before.metadata.Uid != after.metadata.Uid ||
+			before.metadata.Gid != after.metadata.Gid
+	}
+	return false
+}
+
+func sortChanges(changes []Change) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Path != changes[j].Path {
+			return changes[i].Path < changes[j].Path
+		}
+		return changes[i].Type < changes[j].Type
+	})
+}
@@ -0,0 +1,105 @@
+package structure
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func changeStrings(changes []Change) []string {
+	var result []string
+	for _, change := range changes {
+		result = append(result, change.String())
+	}
+	return result
+}
+
+func TestDiff_NoChangesWhenStructureEqual(t *testing.T) {
+	before := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    sub1/\n        file1\n")
+	after := fixtureTree(filepath.Join(osRoot(), "other"), "root/\n    sub1/\n        file1\n")
+	if changes := Diff(before, after); len(changes) != 0 {
+		t.Fatalf("no changes were expected but found: %v", changeStrings(changes))
+	}
+}
+
+func TestDiff_AddedAndRemoved(t *testing.T) {
+	before := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    sub1/\n    file1\n")
+	after := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    sub2/\n        subsub/\n    file2\n")
+	expected := []string{
+		"removed: file1",
+		"added: file2",
+		"removed: sub1",
+		"added: sub2",
+		"added: " + filepath.Join("sub2", "subsub"),
+	}
+	if actual := changeStrings(Diff(before, after)); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+}
+
+func TestDiff_TypeChanged(t *testing.T) {
+	before := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    item/\n        child/\n")
+	after := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    item\n")
+	changes := Diff(before, after)
+	if actual, expected := changeStrings(changes), []string{"type changed: item"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+	if changes[0].Before.Type() != DirectoryNode || changes[0].After.Type() != FileNode {
+		t.Fatal("before and after of type change were incorrect")
+	}
+}
+
+func TestDiff_EntriesSharingAName(t *testing.T) {
+	before := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    item\n    item/\n        child\n")
+	after := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    item/\n        other\n    item -> target\n")
+	changes := Diff(before, after)
+	expected := []string{
+		"added: item",
+		"removed: item",
+		"removed: " + filepath.Join("item", "child"),
+		"added: " + filepath.Join("item", "other"),
+	}
+	if actual := changeStrings(changes); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+	if changes[0].After.Type() != SymlinkNode || changes[1].Before.Type() != FileNode {
+		t.Fatal("entries sharing a name were paired incorrectly")
+	}
+}
+
+func TestDiff_ModifiedWhenDigestsDiffer(t *testing.T) {
+	before := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    file1\n    file2\n")
+	after := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    file1\n    file2\n")
+	before.File("file1").SetDigest([]byte("a"))
+	after.File("file1").SetDigest([]byte("b"))
+	before.File("file2").SetDigest([]byte("a"))
+	after.File("file2").SetDigest([]byte("a"))
+	if actual, expected := changeStrings(Diff(before, after)), []string{"modified: file1"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+}
+
+func TestDiff_ModifiedWhenMetadataDiffers(t *testing.T) {
+	before := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    sub1/\n    file1\n")
+	after := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    sub1/\n    file1\n")
+	before.File("file1").SetMetadata(&Metadata{Size: 1, ModTime: time.Unix(100, 0)})
+	after.File("file1").SetMetadata(&Metadata{Size: 1, ModTime: time.Unix(200, 0)})
+	before.SubDirectory("sub1").SetMetadata(&Metadata{Mode: 0700, ModTime: time.Unix(100, 0)})
+	after.SubDirectory("sub1").SetMetadata(&Metadata{Mode: 0700, ModTime: time.Unix(200, 0)})
+	if actual, expected := changeStrings(Diff(before, after)), []string{"modified: file1"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+}
+
+func TestDiff_SkipsSubtreesWithEqualDigests(t *testing.T) {
+	before := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    sub1/\n        file1\n")
+	after := fixtureTree(filepath.Join(osRoot(), "tmp"), "root/\n    sub1/\n        file1\n")
+	before.ComputeDirectoryHashes(nil)
+	after.ComputeDirectoryHashes(nil)
+	before.SubDirectory("sub1").File("file1").SetMetadata(&Metadata{Size: 1})
+	after.SubDirectory("sub1").File("file1").SetMetadata(&Metadata{Size: 2})
+	if changes := Diff(before, after); len(changes) != 0 {
+		t.Fatalf("no changes were expected but found: %v", changeStrings(changes))
+	}
+}
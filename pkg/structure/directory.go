@@ -1,9 +1,7 @@
 package structure
 
 import (
-	"errors"
-	"fmt"
-	"os"
+	"context"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -14,6 +12,11 @@ type Directory struct {
 	path           string
 	subDirectories map[string]*Directory
 	files          map[string]*File
+	symlinks       map[string]*Symlink
+	linkTarget     string
+	metadata       *Metadata
+	digest         []byte
+	incomplete     bool
 }
 
 // Name returns the name of the Directory
@@ -33,6 +36,35 @@ func (dir Directory) SubDirectories() map[string]*Directory { return dir.subDire
 // pointer to the File
 func (dir Directory) Files() map[string]*File { return dir.files }
 
+// Symlinks returns a map where the key is the name of each Symlink and the value is a
+// pointer to the Symlink
+func (dir Directory) Symlinks() map[string]*Symlink { return dir.symlinks }
+
+// LinkTarget returns the target of the symlink that was followed to reach the Directory
+// It returns an empty string if the Directory is not a followed symlink
+func (dir Directory) LinkTarget() string { return dir.linkTarget }
+
+// IsSymlink determines if the Directory was reached by following a symlink
+func (dir Directory) IsSymlink() bool { return dir.linkTarget != "" }
+
+// Type returns DirectoryNode
+func (dir Directory) Type() NodeType { return DirectoryNode }
+
+// Metadata returns the Metadata captured for the Directory
+// It returns nil if the Directory was not scanned with metadata
+func (dir Directory) Metadata() *Metadata { return dir.metadata }
+
+// SetMetadata replaces the Metadata of the Directory
+func (dir *Directory) SetMetadata(metadata *Metadata) { dir.metadata = metadata }
+
+// Digest returns the Merkle digest of the Directory
+// It returns nil if the Directory has not been hashed
+func (dir Directory) Digest() []byte { return dir.digest }
+
+// Incomplete determines if the Directory or one of its entries could not be read
+// during a scan with CollectErrors, so that it may be missing entries
+func (dir Directory) Incomplete() bool { return dir.incomplete }
+
 // SubDirectory returns a s pointer to a subdirectory named name
 // If returns nil if the given name is not found
 func (dir Directory) SubDirectory(name string) *Directory {
@@ -45,6 +77,12 @@ func (dir Directory) File(name string) *File {
 	return dir.files[name]
 }
 
+// Symlink returns a pointer to a Symlink named name
+// It returns nil if the given name is not found
+func (dir Directory) Symlink(name string) *Symlink {
+	return dir.symlinks[name]
+}
+
 // Equals determines if other is equivalent to the current Directory.
 // It does so using only path and name and therefore does not take
 // into account the structure of either Directory's children.
@@ -66,28 +104,32 @@ func NewDirectory(name string, path string) *Directory {
 // AddDirectory creates a new Directory and adds it to the current Directory tree
 // The new Directory will contain a name and a path specified by fullPath.
 // SubDirectories and Files of the new Directory will be nil
+// An existing Directory at fullPath is replaced along with its subtree.
 // AddDirectory will return the new Directory and an error if fullPath is not a
 // descendant of the current Directory
 func (dir *Directory) AddDirectory(fullPath string) (*Directory, error) {
-	path, name := filepath.Split(fullPath)
-	path = filepath.Clean(path)
-	if !dir.IsSubPath(fullPath) {
-		return nil, errors.New(fmt.Sprintf("fullPath must be a subdirectory of the directory to which it is "+
-			"being added: '%s' is not a subdirectory of '%s'", fullPath, filepath.Join(dir.Path(), dir.Name())))
+	return dir.AddDirectoryWithOptions(fullPath, AddOptions{})
+}
+
+// AddDirectoryWithOptions works like AddDirectory but lets options decide what happens
+// when fullPath is already taken. It returns the existing Directory if it is kept and
+// a *ConflictError if the Directory cannot be added.
+func (dir *Directory) AddDirectoryWithOptions(fullPath string, options AddOptions) (*Directory, error) {
+	pathSlice, name, ok := dir.resolve(fullPath)
+	if !ok {
+		return nil, newPathError("add", fullPath, ErrNotSubPath, "fullPath must be a subdirectory of the directory to which it is "+
+			"being added: '%s' is not a subdirectory of '%s'", fullPath, filepath.Join(dir.Path(), dir.Name()))
 	}
 
-	var parent *Directory
-	newDirectory := NewDirectory(name, path)
-	if relativePath := dir.relativePath(path); relativePath == "" {
-		parent = dir
-	} else {
-		pathSlice := strings.Split(relativePath, string(os.PathSeparator))
-		newParent, err := dir.createPath(pathSlice)
-		if err != nil {
-			return nil, err
-		}
-		parent = newParent
+	parent, err := dir.parentFor(pathSlice, options)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := options.resolve(parent, name, DirectoryNode); err != nil || existing != nil {
+		existingDir, _ := existing.(*Directory)
+		return existingDir, err
 	}
+	newDirectory := NewDirectory(name, parent.FullPath())
 	if parent.subDirectories == nil {
 		parent.subDirectories = map[string]*Directory{}
 	}
@@ -99,18 +141,9 @@ func (dir *Directory) AddDirectory(fullPath string) (*Directory, error) {
 // path is fullPath. It returns the Directory and an error if fullPath is
 // not a descendant of the current Directory.
 func (dir Directory) GetDirectory(fullPath string) (*Directory, error) {
-	path, name := filepath.Split(fullPath)
-	path = filepath.Clean(path)
-	if path == dir.Path() && name == dir.Name() {
-		return &dir, nil
-	}
-	currentDir := filepath.Join(dir.Path(), dir.Name())
-	if len(path) < len(currentDir) || path[:len(currentDir)] != currentDir {
-		return nil, errors.New(fmt.Sprintf("item '%s' is not found in directory '%s'", fullPath, dir.Path()))
-	}
-	pathSlice := strings.Split(strings.TrimPrefix(fullPath, currentDir), string(os.PathSeparator))
-	if len(pathSlice) >= 1 && pathSlice[0] == "" {
-		pathSlice = pathSlice[1:]
+	pathSlice, ok := relativeSegments(dir.FullPath(), fullPath)
+	if !ok {
+		return nil, newPathError("get", fullPath, ErrNotSubPath, "item '%s' is not found in directory '%s'", fullPath, dir.Path())
 	}
 	return dir.findPath(pathSlice)
 }
@@ -152,12 +185,21 @@ func (dir Directory) FindDirectoryBreadth(dirName string) *Directory {
 // MapFnDepth performs a function on every directory in the tree using a depth first approach
 // If any of the functions returns an error, the process is stopped and the error is returned
 func (dir *Directory) MapFnDepth(fn func(directory *Directory) error) error {
+	return dir.MapFnDepthContext(context.Background(), fn)
+}
+
+// MapFnDepthContext works like MapFnDepth but stops before the next directory
+// once ctx is done and returns ctx.Err()
+func (dir *Directory) MapFnDepthContext(ctx context.Context, fn func(directory *Directory) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	err := fn(dir)
 	if err != nil {
 		return err
 	}
 	for _, subDir := range dir.SubDirectories() {
-		err := subDir.MapFnDepth(fn)
+		err := subDir.MapFnDepthContext(ctx, fn)
 		if err != nil {
 			return err
 		}
@@ -168,8 +210,17 @@ func (dir *Directory) MapFnDepth(fn func(directory *Directory) error) error {
 // MapFnBreadth performs a function on every directory in the tree using a breadth first approach
 // If any of the functions returns an error, the process is stopped and the error is returned
 func (dir *Directory) MapFnBreadth(fn func(directory *Directory) error) error {
+	return dir.MapFnBreadthContext(context.Background(), fn)
+}
+
+// MapFnBreadthContext works like MapFnBreadth but stops before the next directory
+// once ctx is done and returns ctx.Err()
+func (dir *Directory) MapFnBreadthContext(ctx context.Context, fn func(directory *Directory) error) error {
 	queue := []*Directory{dir}
 	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		pop := queue[0]
 		queue = queue[1:]
 		if err := fn(pop); err != nil {
@@ -183,6 +234,7 @@ func (dir *Directory) MapFnBreadth(fn func(directory *Directory) error) error {
 }
 
 // Print returns a string containing the directory structure starting from the current directory
+// Use a Renderer for other styles or to write the structure to an io.Writer
 func (dir *Directory) Print() (string, error) {
 	var outputs []string
 	err := dir.MapFnBreadth(func(directory *Directory) error {
@@ -190,6 +242,9 @@ func (dir *Directory) Print() (string, error) {
 		for _, file := range directory.Files() {
 			outputs = append(outputs, file.FullPath())
 		}
+		for _, link := range directory.Symlinks() {
+			outputs = append(outputs, link.FullPath())
+		}
 		sort.Strings(outputs)
 
 		return nil
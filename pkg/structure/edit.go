@@ -0,0 +1,246 @@
+package structure
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RemoveFile removes the File at fullPath from the current Directory tree.
+// It returns the removed File and an error if there is no File at fullPath.
+// The digests of the Directories above it are cleared.
+func (dir *Directory) RemoveFile(fullPath string) (*File, error) {
+	chain, name, err := dir.locate(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	parent := chain[len(chain)-1]
+	file := parent.File(name)
+	if file == nil {
+		return nil, newPathError("remove", fullPath, ErrNotFound,
+			"file '%s' could not be found in directory '%s'", name, parent.FullPath())
+	}
+	delete(parent.files, name)
+	clearDigests(chain)
+	return file, nil
+}
+
+// RemoveDirectory removes the Directory at fullPath and all of its descendants from the
+// current Directory tree. The current Directory itself cannot be removed.
+// It returns the removed Directory and an error if there is no Directory at fullPath.
+// The digests of the Directories above it are cleared.
+func (dir *Directory) RemoveDirectory(fullPath string) (*Directory, error) {
+	chain, name, err := dir.locate(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	parent := chain[len(chain)-1]
+	subDir := parent.SubDirectory(name)
+	if subDir == nil {
+		return nil, newPathError("remove", fullPath, ErrNotFound,
+			"directory '%s' could not be found in directory '%s'", name, parent.FullPath())
+	}
+	delete(parent.subDirectories, name)
+	clearDigests(chain)
+	return subDir, nil
+}
+
+// RemoveSymlink removes the Symlink at fullPath from the current Directory tree.
+// It returns the removed Symlink and an error if there is no Symlink at fullPath.
+// The digests of the Directories above it are cleared.
+func (dir *Directory) RemoveSymlink(fullPath string) (*Symlink, error) {
+	chain, name, err := dir.locate(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	parent := chain[len(chain)-1]
+	link := parent.Symlink(name)
+	if link == nil {
+		return nil, newPathError("remove", fullPath, ErrNotFound,
+			"symlink '%s' could not be found in directory '%s'", name, parent.FullPath())
+	}
+	delete(parent.symlinks, name)
+	clearDigests(chain)
+	return link, nil
+}
+
+// Rename gives the File, Directory or Symlink at fullPath the new name within its
+// parent Directory. See Move for how the tree is updated. The name must not be empty,
+// "." or "..", or contain a separator, which would move the node elsewhere.
+func (dir *Directory) Rename(fullPath string, name string) (Node, error) {
+	parentPath := filepath.Dir(filepath.Clean(fullPath))
+	if err := checkName("rename", parentPath, name); err != nil {
+		return nil, err
+	}
+	return dir.Move(fullPath, filepath.Join(parentPath, name))
+}
+
+// Move moves the File, Directory or Symlink at fullPath to newFullPath in the current
+// Directory tree. The Directory that is to contain newFullPath must already exist and
+// must not contain an entry with the new name. An error matching ErrNameConflict is
+// returned if entries of different types share the name at fullPath, since it is
+// ambiguous which of them is meant. The node itself is moved, so pointers
+// to it remain valid, and the paths of all of its descendants are updated.
+// The digests of the Directories above both the old and the new location are cleared.
+// It returns the moved node and an error if it cannot be moved.
+func (dir *Directory) Move(fullPath string, newFullPath string) (Node, error) {
+	chain, name, err := dir.locate(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	parent := chain[len(chain)-1]
+	nodes := parent.childrenByName()[name]
+	if len(nodes) == 0 {
+		return nil, newPathError("move", fullPath, ErrNotFound,
+			"item '%s' could not be found in directory '%s'", name, parent.FullPath())
+	}
+	if len(nodes) > 1 {
+		return nil, newPathError("move", fullPath, ErrNameConflict,
+			"'%s' is the name of more than one entry in directory '%s'", name, parent.FullPath())
+	}
+	node := nodes[0]
+	newChain, newName, err := dir.locate(newFullPath)
+	if err != nil {
+		return nil, err
+	}
+	newParent := newChain[len(newChain)-1]
+	if newParent == parent && newName == name {
+		return node, nil
+	}
+	if !validName(newName) {
+		return nil, newPathError("move", newFullPath, ErrInvalidName, "'%s' is not a valid name", newName)
+	}
+	if existing := newParent.childrenByName()[newName]; len(existing) > 0 {
+		return nil, &ConflictError{Path: filepath.Join(newParent.FullPath(), newName), Existing: existing[0].Type(), Added: node.Type()}
+	}
+	for _, ancestor := range newChain {
+		if Node(ancestor) == node {
+			return nil, newPathError("move", newFullPath, ErrMoveIntoItself, "directory '%s' cannot be moved into itself", fullPath)
+		}
+	}
+
+	parent.removeNode(node)
+	switch node := node.(type) {
+	case *Directory:
+		node.relocate(newName, newParent.FullPath())
+		if newParent.subDirectories == nil {
+			newParent.subDirectories = map[string]*Directory{}
+		}
+		newParent.subDirectories[newName] = node
+	case *File:
+		node.name, node.path = newName, newParent.FullPath()
+		if newParent.files == nil {
+			newParent.files = map[string]*File{}
+		}
+		newParent.files[newName] = node
+	case *Symlink:
+		node.name, node.path = newName, newParent.FullPath()
+		if newParent.symlinks == nil {
+			newParent.symlinks = map[string]*Symlink{}
+		}
+		newParent.symlinks[newName] = node
+	}
+	clearDigests(chain)
+	clearDigests(newChain)
+	return node, nil
+}
+
+// locate finds the Directory that is to hold the entry at fullPath. It returns the
+// Directories from the current Directory down to that Directory, the name of the entry
+// and an error if fullPath is not a descendant of the current Directory or one of the
+// Directories on the way does not exist.
+func (dir *Directory) locate(fullPath string) ([]*Directory, string, error) {
+	pathSlice, name, ok := dir.resolve(fullPath)
+	if !ok {
+		return nil, "", newPathError("locate", fullPath, ErrNotSubPath, "'%s' is not a descendant of '%s'", fullPath, dir.FullPath())
+	}
+	chain := []*Directory{dir}
+	for _, segment := range pathSlice {
+		next := chain[len(chain)-1].SubDirectory(segment)
+		if next == nil {
+			return nil, "", newPathError("locate", fullPath, ErrNotFound, "directory '%s' could not be found in directory '%s'",
+				segment, chain[len(chain)-1].FullPath())
+		}
+		chain = append(chain, next)
+	}
+	return chain, name, nil
+}
+
+// validName determines if name can be the name of an entry in a Directory
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsRune(name, os.PathSeparator)
+}
+
+// checkName returns a *PathError matching ErrInvalidName if name cannot be the name
+// of an entry in the Directory at path
+func checkName(op string, path string, name string) error {
+	if validName(name) {
+		return nil
+	}
+	return newPathError(op, strings.TrimSuffix(path, string(os.PathSeparator))+string(os.PathSeparator)+name,
+		ErrInvalidName, "'%s' is not a valid name", name)
+}
+
+// childNames checks the names of the entries of the Directory at path as they are read.
+// Every name must be valid and may only be used once.
+type childNames struct {
+	op   string
+	path string
+	seen map[string]bool
+}
+
+func (names *childNames) add(name string) error {
+	if err := checkName(names.op, names.path, name); err != nil {
+		return err
+	}
+	if names.seen == nil {
+		names.seen = map[string]bool{}
+	}
+	if names.seen[name] {
+		return newPathError(names.op, filepath.Join(names.path, name), ErrNameConflict,
+			"'%s' is listed twice in '%s'", name, names.path)
+	}
+	names.seen[name] = true
+	return nil
+}
+
+// clearDigests clears the digests of every Directory in chain
+func clearDigests(chain []*Directory) {
+	for _, dir := range chain {
+		dir.digest = nil
+	}
+}
+
+// removeChild removes the entry name from dir, whatever its type
+func (dir *Directory) removeChild(name string) {
+	delete(dir.subDirectories, name)
+	delete(dir.files, name)
+	delete(dir.symlinks, name)
+}
+
+// removeNode removes node from dir, leaving entries of other types with its name alone
+func (dir *Directory) removeNode(node Node) {
+	switch node.Type() {
+	case DirectoryNode:
+		delete(dir.subDirectories, node.Name())
+	case FileNode:
+		delete(dir.files, node.Name())
+	case SymlinkNode:
+		delete(dir.symlinks, node.Name())
+	}
+}
+
+// relocate gives dir a new name and path and updates the paths of its descendants
+func (dir *Directory) relocate(name string, path string) {
+	dir.name, dir.path = name, path
+	fullPath := dir.FullPath()
+	for _, subDir := range dir.subDirectories {
+		subDir.relocate(subDir.name, fullPath)
+	}
+	for _, file := range dir.files {
+		file.path = fullPath
+	}
+	for _, link := range dir.symlinks {
+		link.path = fullPath
+	}
+}
@@ -0,0 +1,146 @@
+package structure
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+const editSpec = `
+dir1/
+    file1
+    sub1/
+        file2
+        sub2/
+            file3
+            link -> ../file2
+    sub3/
+`
+
+func TestDirectory_RemoveFile(t *testing.T) {
+	dir := fixtureTree(filepath.Join(osRoot(), "tmp"), editSpec)
+	dir.ComputeDirectoryHashes(nil)
+	root := dir.FullPath()
+	file, err := dir.RemoveFile(filepath.Join(root, "sub1", "file2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name() != "file2" || dir.SubDirectory("sub1").File("file2") != nil {
+		t.Fatal("file was not removed")
+	}
+	if dir.Digest() != nil || dir.SubDirectory("sub1").Digest() != nil {
+		t.Fatal("digests of the directories above the file were not cleared")
+	}
+	if dir.SubDirectory("sub3").Digest() == nil {
+		t.Fatal("digest of an unrelated directory was cleared")
+	}
+	if _, err := dir.RemoveFile(filepath.Join(root, "sub1", "file2")); err == nil {
+		t.Fatal("removing a missing file should have returned an error")
+	}
+	if _, err := dir.RemoveFile(filepath.Join(root, "sub1")); err == nil {
+		t.Fatal("removing a directory as a file should have returned an error")
+	}
+}
+
+func TestDirectory_RemoveDirectory(t *testing.T) {
+	dir := fixtureTree(filepath.Join(osRoot(), "tmp"), editSpec)
+	root := dir.FullPath()
+	subDir, err := dir.RemoveDirectory(filepath.Join(root, "sub1", "sub2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subDir.File("file3") == nil || dir.SubDirectory("sub1").SubDirectory("sub2") != nil {
+		t.Fatal("directory was not removed along with its subtree")
+	}
+	if _, err := dir.RemoveDirectory(root); err == nil {
+		t.Fatal("removing the current directory should have returned an error")
+	}
+	if _, err := dir.RemoveSymlink(filepath.Join(root, "sub1", "sub2", "link")); err == nil {
+		t.Fatal("removing a symlink from a removed directory should have returned an error")
+	}
+}
+
+func TestDirectory_Rename(t *testing.T) {
+	dir := fixtureTree(filepath.Join(osRoot(), "tmp"), editSpec)
+	root := dir.FullPath()
+	sub1 := dir.SubDirectory("sub1")
+	node, err := dir.Rename(filepath.Join(root, "sub1"), "renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node != Node(sub1) || dir.SubDirectory("renamed") != sub1 || dir.SubDirectory("sub1") != nil {
+		t.Fatal("directory was not renamed in place")
+	}
+	expected := filepath.Join(root, "renamed", "sub2", "link")
+	if actual := sub1.SubDirectory("sub2").Symlink("link").FullPath(); actual != expected {
+		t.Fatalf("descendant path was not rewritten. expected: %s actual: %s", expected, actual)
+	}
+	if found, err := dir.GetFile(filepath.Join(root, "renamed", "sub2", "file3")); err != nil || found == nil {
+		t.Fatalf("renamed descendant could not be found: %v", err)
+	}
+	for _, name := range []string{"", "..", filepath.Join("a", "b"), "file1"} {
+		if _, err := dir.Rename(filepath.Join(root, "renamed"), name); err == nil {
+			t.Fatalf("renaming to '%s' should have returned an error", name)
+		}
+	}
+	for _, name := range []string{".", filepath.Join("..", "sub3", "moved"), filepath.Join("..", "file4")} {
+		if _, err := dir.Rename(filepath.Join(root, "renamed", "file2"), name); !errors.Is(err, ErrInvalidName) {
+			t.Fatalf("renaming to '%s' should have returned ErrInvalidName but got %v", name, err)
+		}
+	}
+	if dir.SubDirectory("renamed").File("file2") == nil || len(dir.SubDirectory("sub3").Files()) != 0 || dir.File("file4") != nil {
+		t.Fatal("file was moved by an invalid rename")
+	}
+}
+
+func TestDirectory_Move(t *testing.T) {
+	dir := fixtureTree(filepath.Join(osRoot(), "tmp"), editSpec)
+	root := dir.FullPath()
+	sub2 := dir.SubDirectory("sub1").SubDirectory("sub2")
+	if _, err := dir.Move(filepath.Join(root, "sub1", "sub2"), filepath.Join(root, "sub3", "moved")); err != nil {
+		t.Fatal(err)
+	}
+	if dir.SubDirectory("sub3").SubDirectory("moved") != sub2 || dir.SubDirectory("sub1").SubDirectory("sub2") != nil {
+		t.Fatal("directory was not moved")
+	}
+	if expected := filepath.Join(root, "sub3", "moved", "file3"); sub2.File("file3").FullPath() != expected {
+		t.Fatalf("descendant path was not rewritten. expected: %s actual: %s", expected, sub2.File("file3").FullPath())
+	}
+	if _, err := dir.Move(filepath.Join(root, "file1"), filepath.Join(root, "sub3", "moved", "file1")); err != nil {
+		t.Fatal(err)
+	}
+	if dir.File("file1") != nil || sub2.File("file1") == nil {
+		t.Fatal("file was not moved")
+	}
+	if _, err := dir.Move(filepath.Join(root, "sub3"), filepath.Join(root, "sub3", "moved", "inside")); err == nil {
+		t.Fatal("moving a directory into itself should have returned an error")
+	}
+	if _, err := dir.Move(filepath.Join(root, "sub1"), filepath.Join(root, "missing", "sub1")); err == nil {
+		t.Fatal("moving into a missing directory should have returned an error")
+	}
+}
+
+func TestDirectory_Move_EntriesSharingAName(t *testing.T) {
+	dir := fixtureTree(filepath.Join(osRoot(), "tmp"), "dir1/\n    x\n    x/\n        file1\n    y/\n        x -> target\n")
+	root := dir.FullPath()
+	if _, err := dir.Move(filepath.Join(root, "x"), filepath.Join(root, "y", "z")); !errors.Is(err, ErrNameConflict) {
+		t.Fatalf("moving an ambiguous name should have returned ErrNameConflict but got %v", err)
+	}
+	if _, err := dir.Rename(filepath.Join(root, "x"), "z"); !errors.Is(err, ErrNameConflict) {
+		t.Fatalf("renaming an ambiguous name should have returned ErrNameConflict but got %v", err)
+	}
+	if dir.File("x") == nil || dir.SubDirectory("x") == nil {
+		t.Fatal("entries sharing a name were changed by a failed move")
+	}
+
+	if _, err := dir.RemoveFile(filepath.Join(root, "x")); err != nil {
+		t.Fatal(err)
+	}
+	moved := dir.SubDirectory("x")
+	if _, err := dir.Move(filepath.Join(root, "x"), filepath.Join(root, "y", "z")); err != nil {
+		t.Fatal(err)
+	}
+	if dir.SubDirectory("y").SubDirectory("z") != moved || dir.SubDirectory("y").Symlink("x") == nil {
+		t.Fatal("directory was not moved once its name was no longer shared")
+	}
+}
@@ -0,0 +1,85 @@
+package structure
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrNotFound is reported when an entry does not exist in a Directory tree
+	ErrNotFound = errors.New("structure: not found")
+	// ErrNotDirectory is reported when a path that must be a directory is something else
+	ErrNotDirectory = errors.New("structure: not a directory")
+	// ErrNotSubPath is reported when a path is not a descendant of the Directory it is used with
+	ErrNotSubPath = errors.New("structure: not a sub path")
+	// ErrNameConflict is reported when an entry cannot be added or moved because its name is taken
+	ErrNameConflict = errors.New("structure: name conflict")
+	// ErrUnreadable is reported when an entry on disk exists but cannot be read
+	ErrUnreadable = errors.New("structure: unreadable")
+	// ErrInvalidName is reported when a name is empty, "." or "..", or contains a separator
+	ErrInvalidName = errors.New("structure: invalid name")
+	// ErrMoveIntoItself is reported when a Directory is to be moved below itself
+	ErrMoveIntoItself = errors.New("structure: cannot move a directory into itself")
+)
+
+// PathError records an error and the path that caused it. Err is one of the errors
+// above, so errors.Is can be used to tell errors apart, and Cause is the underlying
+// error if there is one, such as the *os.PathError of a path that cannot be read.
+// errors.Is and errors.As see through to the Cause, so errors.Is(err, os.ErrNotExist)
+// works, but os.IsNotExist does not.
+type PathError struct {
+	Op    string
+	Path  string
+	Err   error
+	Cause error
+
+	message string
+}
+
+func newPathError(op string, path string, kind error, format string, args ...interface{}) *PathError {
+	return &PathError{Op: op, Path: path, Err: kind, message: fmt.Sprintf(format, args...)}
+}
+
+func (err *PathError) Error() string {
+	message := err.message
+	if message == "" {
+		message = fmt.Sprintf("%s %s: %v", err.Op, err.Path, err.Err)
+	}
+	if err.Cause != nil {
+		message += ": " + err.Cause.Error()
+	}
+	return message
+}
+
+// readError creates the *PathError for the path on disk that could not be read because of err
+func readError(op string, path string, err error) *PathError {
+	kind := ErrUnreadable
+	if os.IsNotExist(err) {
+		kind = ErrNotFound
+	}
+	failure := newPathError(op, path, kind, "'%s' could not be read", path)
+	failure.Cause = err
+	return failure
+}
+
+// Is reports whether target is the kind of error recorded in Err
+func (err *PathError) Is(target error) bool { return target == err.Err }
+
+// Unwrap returns the underlying error
+func (err *PathError) Unwrap() error { return err.Cause }
+
+// ScanError lists every path that could not be read during a scan with CollectErrors.
+// Failures are sorted by path and each one carries the error that occurred as its Cause.
+type ScanError struct {
+	Failures []*PathError
+}
+
+func (err *ScanError) Error() string {
+	lines := []string{fmt.Sprintf("%d paths could not be read", len(err.Failures))}
+	for _, failure := range err.Failures {
+		lines = append(lines, failure.Error())
+	}
+	return strings.Join(lines, "\n\t")
+}
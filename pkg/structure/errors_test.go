@@ -0,0 +1,118 @@
+package structure
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrors_AreInspectable(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	root := dir.FullPath()
+	if _, err := dir.AddFile(filepath.Join(root, "sub1", "file1")); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(osRoot(), "tmp", "dir10", "x")
+	tests := []struct {
+		name     string
+		expected error
+		path     string
+		call     func() error
+	}{
+		{"add directory outside", ErrNotSubPath, outside, func() error { _, err := dir.AddDirectory(outside); return err }},
+		{"add file outside", ErrNotSubPath, outside, func() error { _, err := dir.AddFile(outside); return err }},
+		{"add symlink outside", ErrNotSubPath, outside, func() error { _, err := dir.AddSymlink(outside, "target"); return err }},
+		{"get directory outside", ErrNotSubPath, outside, func() error { _, err := dir.GetDirectory(outside); return err }},
+		{"get missing directory", ErrNotFound, filepath.Join(root, "sub2"), func() error {
+			_, err := dir.GetDirectory(filepath.Join(root, "sub2"))
+			return err
+		}},
+		{"get missing file", ErrNotFound, filepath.Join(root, "sub1", "file2"), func() error {
+			_, err := dir.GetFile(filepath.Join(root, "sub1", "file2"))
+			return err
+		}},
+		{"remove missing file", ErrNotFound, filepath.Join(root, "sub1", "file2"), func() error {
+			_, err := dir.RemoveFile(filepath.Join(root, "sub1", "file2"))
+			return err
+		}},
+		{"remove below missing directory", ErrNotFound, filepath.Join(root, "sub2", "file1"), func() error {
+			_, err := dir.RemoveFile(filepath.Join(root, "sub2", "file1"))
+			return err
+		}},
+		{"join outside", ErrNotSubPath, filepath.Join(osRoot(), "tmp", "dir10"), func() error {
+			_, err := SafeJoin(root, "..", "dir10")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("expected %v but got %v", tt.expected, err)
+			}
+			var pathErr *PathError
+			if !errors.As(err, &pathErr) || pathErr.Path != tt.path {
+				t.Fatalf("expected a *PathError for '%s' but got %#v", tt.path, err)
+			}
+		})
+	}
+}
+
+func TestErrors_Move(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	root := dir.FullPath()
+	if _, err := dir.AddFile(filepath.Join(root, "sub1", "sub2", "file1")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := dir.Move(filepath.Join(root, "sub1"), filepath.Join(root, "sub1", "sub2", "sub1"))
+	var pathErr *PathError
+	if !errors.Is(err, ErrMoveIntoItself) || !errors.As(err, &pathErr) || pathErr.Path != filepath.Join(root, "sub1", "sub2", "sub1") {
+		t.Fatalf("expected a *PathError matching ErrMoveIntoItself but got %v", err)
+	}
+	if _, err := dir.Move(filepath.Join(root, "sub1"), filepath.Join(root, "..")); !errors.Is(err, ErrNotSubPath) {
+		t.Fatalf("expected ErrNotSubPath but got %v", err)
+	}
+}
+
+func TestErrors_NameConflict(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	root := dir.FullPath()
+	if _, err := dir.AddFile(filepath.Join(root, "file1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.AddFile(filepath.Join(root, "file2")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := dir.AddFileWithOptions(filepath.Join(root, "file1"), AddOptions{Conflict: ErrorOnConflict})
+	if !errors.Is(err, ErrNameConflict) {
+		t.Fatalf("expected ErrNameConflict but got %v", err)
+	}
+	_, err = dir.Move(filepath.Join(root, "file1"), filepath.Join(root, "file2"))
+	var conflict *ConflictError
+	if !errors.Is(err, ErrNameConflict) || !errors.As(err, &conflict) || conflict.Path != filepath.Join(root, "file2") {
+		t.Fatalf("expected a *ConflictError for the moved file but got %v", err)
+	}
+}
+
+func TestPathError_Unwrap(t *testing.T) {
+	cause := errors.New("cause")
+	err := &PathError{Op: "scan", Path: "/tmp/dir1", Err: ErrNotFound, Cause: cause}
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, cause) || errors.Is(err, ErrNotDirectory) {
+		t.Fatal("error did not match its kind and cause")
+	}
+	if expected := "scan /tmp/dir1: structure: not found: cause"; err.Error() != expected {
+		t.Fatalf("expected '%s' but got '%s'", expected, err.Error())
+	}
+}
+
+func TestScanError_Error(t *testing.T) {
+	err := &ScanError{Failures: []*PathError{
+		{Op: "scan", Path: "/tmp/dir1", Err: ErrUnreadable, Cause: errors.New("permission denied")},
+		{Op: "scan", Path: "/tmp/dir2", Err: ErrNotFound},
+	}}
+	expected := "2 paths could not be read\n\tscan /tmp/dir1: structure: unreadable: permission denied" +
+		"\n\tscan /tmp/dir2: structure: not found"
+	if err.Error() != expected {
+		t.Fatalf("expected '%s' but got '%s'", expected, err.Error())
+	}
+}
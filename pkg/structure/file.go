@@ -1,16 +1,14 @@
 package structure
 
 import (
-	"errors"
-	"fmt"
-	"os"
 	"path/filepath"
-	"strings"
 )
 
 type File struct {
-	name string
-	path string
+	name     string
+	path     string
+	metadata *Metadata
+	digest   []byte
 }
 
 // Name returns the name of the File
@@ -22,6 +20,23 @@ func (file File) Path() string { return filepath.Clean(file.path) }
 // FullPath returns the full path to the File including the File itself
 func (file File) FullPath() string { return filepath.Clean(filepath.Join(file.path, file.name)) }
 
+// Type returns FileNode
+func (file File) Type() NodeType { return FileNode }
+
+// Metadata returns the Metadata captured for the File
+// It returns nil if the File was not scanned with metadata
+func (file File) Metadata() *Metadata { return file.metadata }
+
+// SetMetadata replaces the Metadata of the File
+func (file *File) SetMetadata(metadata *Metadata) { file.metadata = metadata }
+
+// Digest returns the digest of the contents of the File
+// It returns nil if the File has not been hashed
+func (file File) Digest() []byte { return file.digest }
+
+// SetDigest replaces the digest of the File
+func (file *File) SetDigest(digest []byte) { file.digest = digest }
+
 // Equals determines if other is equivalent to the current File.
 func (file File) Equals(other *File) bool {
 	return filepath.Clean(file.path) == filepath.Clean(other.path) &&
@@ -37,27 +52,32 @@ func NewFile(name string, path string) File {
 
 // AddFile creates a new File and adds it to the current Directory tree
 // The new File will contain a name and a path specified by fullPath.
+// An existing File at fullPath is replaced.
 // AddDirectory will return the new File and an error if fullPath is not a
 // descendant of the current Directory
 func (dir *Directory) AddFile(fullPath string) (*File, error) {
-	path, name := filepath.Split(fullPath)
-	path = filepath.Clean(path)
-	if !dir.IsSubPath(fullPath) {
-		return nil, errors.New("fullPath must be an immediate child of the directory to which it is being added")
+	return dir.AddFileWithOptions(fullPath, AddOptions{})
+}
+
+// AddFileWithOptions works like AddFile but lets options decide what happens when
+// fullPath is already taken. It returns the existing File if it is kept and a
+// *ConflictError if the File cannot be added.
+func (dir *Directory) AddFileWithOptions(fullPath string, options AddOptions) (*File, error) {
+	pathSlice, name, ok := dir.resolve(fullPath)
+	if !ok {
+		return nil, newPathError("add", fullPath, ErrNotSubPath,
+			"fullPath must be an immediate child of the directory to which it is being added")
 	}
 
-	var parent *Directory
-	newFile := NewFile(name, path)
-	if relativePath := dir.relativePath(path); relativePath == "" {
-		parent = dir
-	} else {
-		pathSlice := strings.Split(relativePath, string(os.PathSeparator))
-		newParent, err := dir.createPath(pathSlice)
-		if err != nil {
-			return nil, err
-		}
-		parent = newParent
+	parent, err := dir.parentFor(pathSlice, options)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := options.resolve(parent, name, FileNode); err != nil || existing != nil {
+		existingFile, _ := existing.(*File)
+		return existingFile, err
 	}
+	newFile := NewFile(name, parent.FullPath())
 	if parent.files == nil {
 		parent.files = map[string]*File{}
 	}
@@ -69,7 +89,7 @@ func (dir *Directory) AddFile(fullPath string) (*File, error) {
 // path is fullPath. It returns the File and an error if fullPath is
 // not a descendant of the current Directory.
 func (dir Directory) GetFile(fullPath string) (*File, error) {
-	path, name := filepath.Split(fullPath)
+	path, name := filepath.Split(filepath.Clean(fullPath))
 	path = filepath.Clean(path)
 	fileDir, err := dir.GetDirectory(path)
 	if err != nil {
@@ -79,7 +99,7 @@ func (dir Directory) GetFile(fullPath string) (*File, error) {
 	if file := fileDir.File(name); file != nil {
 		return file, nil
 	}
-	return nil, errors.New(fmt.Sprintf("file could not be found in directory '%s'", dir.Path()))
+	return nil, newPathError("get", fullPath, ErrNotFound, "file could not be found in directory '%s'", dir.Path())
 }
 
 // FindFileDepth searches the directory tree for a File using depth first search.
@@ -0,0 +1,145 @@
+package structure
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern from a .gitignore or exclude file
+type ignoreRule struct {
+	pattern  string
+	base     string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreRules holds every rule that applies to a directory in the order git
+// reads them. When several rules match a path, the last one wins.
+type ignoreRules []ignoreRule
+
+// parseIgnoreRules parses the contents of a .gitignore or exclude file. base is the
+// slash separated path of the directory the rules are relative to.
+func parseIgnoreRules(data []byte, base string) ignoreRules {
+	var rules ignoreRules
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	for lines.Scan() {
+		line := trimIgnoreLine(strings.TrimSuffix(lines.Text(), "\r"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// trimIgnoreLine removes trailing spaces unless they are escaped with a backslash
+func trimIgnoreLine(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// ignored determines if the slash separated relPath should be left out of the tree
+func (rules ignoreRules) ignored(relPath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.matches(relPath, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (rule ignoreRule) matches(relPath string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+	if rule.base != "" {
+		if !strings.HasPrefix(relPath, rule.base+"/") {
+			return false
+		}
+		relPath = relPath[len(rule.base)+1:]
+	}
+	if rule.anchored {
+		return wildmatch(rule.pattern, relPath)
+	}
+	return wildmatch(rule.pattern, path.Base(relPath))
+}
+
+// withIgnoreFile returns rules extended by the .gitignore in the directory at diskPath,
+// if there is one. base is the slash separated path of that directory relative to
+// the root of the repository.
+func (rules ignoreRules) withIgnoreFile(diskPath string, base string) ignoreRules {
+	data, err := ioutil.ReadFile(filepath.Join(diskPath, ".gitignore"))
+	if err != nil {
+		return rules
+	}
+	parsed := parseIgnoreRules(data, base)
+	if len(parsed) == 0 {
+		return rules
+	}
+	return append(rules[:len(rules):len(rules)], parsed...)
+}
+
+// repositoryIgnoreRules finds the git repository that contains diskPath and returns
+// the rules from .git/info/exclude and every .gitignore between the root of the
+// repository and diskPath, excluding diskPath itself. It also returns the slash
+// separated path of diskPath relative to the root of the repository.
+// If diskPath is not inside a repository, diskPath is treated as the root.
+func repositoryIgnoreRules(diskPath string) (ignoreRules, string) {
+	diskPath, err := filepath.Abs(diskPath)
+	if err != nil {
+		return nil, ""
+	}
+	repoRoot := diskPath
+	for dir := diskPath; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			repoRoot = dir
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	var rules ignoreRules
+	if data, err := ioutil.ReadFile(filepath.Join(repoRoot, ".git", "info", "exclude")); err == nil {
+		rules = parseIgnoreRules(data, "")
+	}
+	prefix, err := filepath.Rel(repoRoot, diskPath)
+	if err != nil || prefix == "." {
+		return rules, ""
+	}
+	prefix = filepath.ToSlash(prefix)
+	base := ""
+	for _, segment := range strings.Split(prefix, "/") {
+		rules = rules.withIgnoreFile(filepath.Join(repoRoot, filepath.FromSlash(base)), base)
+		base = path.Join(base, segment)
+	}
+	return rules, prefix
+}
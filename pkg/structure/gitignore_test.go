@@ -0,0 +1,63 @@
+package structure
+
+import "testing"
+
+var gitignoreTests = []struct {
+	name    string
+	rules   string
+	path    string
+	isDir   bool
+	ignored bool
+}{
+	{"BasenameAnyDepth", "*.log", "a/b/debug.log", false, true},
+	{"BasenameNoMatch", "*.log", "a/b/debug.txt", false, false},
+	{"Comment", "#*.log", "debug.log", false, false},
+	{"EscapedHash", `\#file`, "#file", false, true},
+	{"Negation", "*.log\n!keep.log", "keep.log", false, false},
+	{"NegationOrder", "!keep.log\n*.log", "keep.log", false, true},
+	{"EscapedBang", `\!important`, "!important", false, true},
+	{"DirectoryOnlyMatchesDirectory", "build/", "build", true, true},
+	{"DirectoryOnlySkipsFile", "build/", "build", false, false},
+	{"AnchoredAtRoot", "/todo", "todo", false, true},
+	{"AnchoredNotNested", "/todo", "a/todo", false, false},
+	{"MiddleSlashAnchors", "doc/*.txt", "doc/notes.txt", false, true},
+	{"MiddleSlashNotNested", "doc/*.txt", "a/doc/notes.txt", false, false},
+	{"LeadingDoubleStar", "**/logs", "a/b/logs", true, true},
+	{"TrailingDoubleStar", "abc/**", "abc/x/y", false, true},
+	{"TrailingSpaces", "foo   ", "foo", false, true},
+	{"EscapedTrailingSpace", `foo\ `, "foo ", false, true},
+}
+
+func TestIgnoreRules_Ignored(t *testing.T) {
+	for _, tt := range gitignoreTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := parseIgnoreRules([]byte(tt.rules), "")
+			if actual := rules.ignored(tt.path, tt.isDir); actual != tt.ignored {
+				t.Fatalf("'%s' ignored was %t but expected %t", tt.path, actual, tt.ignored)
+			}
+		})
+	}
+}
+
+func TestIgnoreRules_Ignored_RelativeToBase(t *testing.T) {
+	rules := parseIgnoreRules([]byte("/out\n*.tmp"), "sub")
+	if !rules.ignored("sub/out", true) {
+		t.Fatal("anchored pattern did not match relative to its base")
+	}
+	if rules.ignored("out", true) {
+		t.Fatal("pattern matched outside of its base")
+	}
+	if !rules.ignored("sub/a/x.tmp", false) {
+		t.Fatal("pattern did not match below its base")
+	}
+}
+
+func TestIgnoreRules_Ignored_DeeperRulesWin(t *testing.T) {
+	rules := append(parseIgnoreRules([]byte("*.gen"), ""), parseIgnoreRules([]byte("!keep.gen"), "sub")...)
+	if rules.ignored("sub/keep.gen", false) {
+		t.Fatal("deeper negation did not override shallower rule")
+	}
+	if !rules.ignored("keep.gen", false) {
+		t.Fatal("deeper negation applied outside of its base")
+	}
+}
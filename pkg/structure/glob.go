@@ -0,0 +1,63 @@
+package structure
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+)
+
+// Glob finds every descendant of the current Directory whose path relative to the
+// current Directory matches pattern. Patterns always use '/' as the separator.
+// '*' matches any run of characters within a segment, '?' matches a single character,
+// '[...]' matches a character class and a "**" segment matches any number of
+// segments, so "src/**/*_test.go" finds test files at any depth below src.
+// Matches are sorted by path. It returns filepath.ErrBadPattern if pattern is malformed.
+func (dir Directory) Glob(pattern string) (Descendants, error) {
+	return dir.GlobContext(context.Background(), pattern)
+}
+
+// GlobContext works like Glob but stops once ctx is done. It then returns
+// the matches found so far along with ctx.Err().
+func (dir Directory) GlobContext(ctx context.Context, pattern string) (Descendants, error) {
+	if err := validatePattern(pattern); err != nil {
+		return Descendants{}, err
+	}
+	var matches Descendants
+	err := dir.glob(ctx, pattern, "", &matches)
+	sort.Slice(matches.Directories, func(i, j int) bool {
+		return matches.Directories[i].FullPath() < matches.Directories[j].FullPath()
+	})
+	sort.Slice(matches.Files, func(i, j int) bool {
+		return matches.Files[i].FullPath() < matches.Files[j].FullPath()
+	})
+	sort.Slice(matches.Symlinks, func(i, j int) bool {
+		return matches.Symlinks[i].FullPath() < matches.Symlinks[j].FullPath()
+	})
+	return matches, err
+}
+
+func (dir Directory) glob(ctx context.Context, pattern string, relPath string, matches *Descendants) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for name, file := range dir.files {
+		if wildmatch(pattern, filepath.ToSlash(filepath.Join(relPath, name))) {
+			matches.Files = append(matches.Files, file)
+		}
+	}
+	for name, link := range dir.symlinks {
+		if wildmatch(pattern, filepath.ToSlash(filepath.Join(relPath, name))) {
+			matches.Symlinks = append(matches.Symlinks, link)
+		}
+	}
+	for name, subDir := range dir.subDirectories {
+		subPath := filepath.Join(relPath, name)
+		if wildmatch(pattern, filepath.ToSlash(subPath)) {
+			matches.Directories = append(matches.Directories, subDir)
+		}
+		if err := subDir.glob(ctx, pattern, subPath, matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package structure
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var globPaths = []string{
+	"README.md",
+	"src/main.go",
+	"src/main_test.go",
+	"src/pkg/util.go",
+	"src/pkg/util_test.go",
+	"src/pkg/deep/deep_test.go",
+	"docs/a1.md",
+	"docs/b2.md",
+}
+
+func relativePaths(dir *Directory, desc Descendants) (dirs []string, files []string) {
+	for _, d := range desc.Directories {
+		dirs = append(dirs, dir.relativePath(d.FullPath()))
+	}
+	for _, f := range desc.Files {
+		files = append(files, dir.relativePath(f.FullPath()))
+	}
+	return
+}
+
+var globTests = []struct {
+	name    string
+	pattern string
+	dirs    []string
+	files   []string
+}{
+	{"Star", "*.md", nil, []string{"README.md"}},
+	{"RecursiveTests", "src/**/*_test.go", nil, []string{
+		filepath.Join("src", "main_test.go"),
+		filepath.Join("src", "pkg", "deep", "deep_test.go"),
+		filepath.Join("src", "pkg", "util_test.go"),
+	}},
+	{"QuestionMark", "docs/?1.md", nil, []string{filepath.Join("docs", "a1.md")}},
+	{"CharacterClass", "docs/[b-z]*", nil, []string{filepath.Join("docs", "b2.md")}},
+	{"Directories", "src/*", []string{filepath.Join("src", "pkg")}, []string{
+		filepath.Join("src", "main.go"),
+		filepath.Join("src", "main_test.go"),
+	}},
+	{"LeadingDoubleStar", "**/util.go", nil, []string{filepath.Join("src", "pkg", "util.go")}},
+	{"NoMatch", "*.txt", nil, nil},
+}
+
+func TestDirectory_Glob(t *testing.T) {
+	dir := pathTree(filepath.Join(osRoot(), "tmp"), globPaths...)
+	for _, tt := range globTests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := dir.Glob(tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dirs, files := relativePaths(dir, matches)
+			if !reflect.DeepEqual(dirs, tt.dirs) {
+				t.Fatalf("directories did not match. expected: %v actual: %v", tt.dirs, dirs)
+			}
+			if !reflect.DeepEqual(files, tt.files) {
+				t.Fatalf("files did not match. expected: %v actual: %v", tt.files, files)
+			}
+		})
+	}
+}
+
+func TestDirectory_Glob_MatchesSymlinks(t *testing.T) {
+	dir := pathTree(filepath.Join(osRoot(), "tmp"), globPaths...)
+	link, err := dir.AddSymlink(filepath.Join(osRoot(), "tmp", "root", "src", "current.go"), "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := dir.Glob("src/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches.Symlinks) != 1 || matches.Symlinks[0] != link {
+		t.Fatalf("expected the symlink to match but got %v", matches.Symlinks)
+	}
+	if len(matches.Files) != 2 {
+		t.Fatalf("expected 2 files to match but got %d", len(matches.Files))
+	}
+}
+
+func TestDirectory_Glob_ReturnsErrorForBadPattern(t *testing.T) {
+	dir := pathTree(filepath.Join(osRoot(), "tmp"), globPaths...)
+	if _, err := dir.Glob("src/[a-"); err != filepath.ErrBadPattern {
+		t.Fatalf("error was incorrect. expected: %v actual: %v", filepath.ErrBadPattern, err)
+	}
+}
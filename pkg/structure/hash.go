@@ -0,0 +1,125 @@
+package structure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+	"sort"
+)
+
+// DefaultHash is the hash used by ComputeHashes and ComputeDirectoryHashes
+// when no hash is given
+var DefaultHash = sha256.New
+
+// ComputeHashes reads every File in the tree from disk and stores a digest of
+// its contents on the File. Afterwards every Directory is given a Merkle digest
+// by ComputeDirectoryHashes. Files are read from their FullPath, so the tree
+// must not have been scanned as relative. If newHash is nil, DefaultHash is used.
+// It returns an error if any File could not be read.
+func (dir *Directory) ComputeHashes(newHash func() hash.Hash) error {
+	if newHash == nil {
+		newHash = DefaultHash
+	}
+	err := dir.MapFnDepth(func(directory *Directory) error {
+		for _, file := range directory.files {
+			digest, err := hashFile(file.FullPath(), newHash())
+			if err != nil {
+				return err
+			}
+			file.digest = digest
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	dir.ComputeDirectoryHashes(newHash)
+	return nil
+}
+
+// ComputeDirectoryHashes gives every Directory in the tree a Merkle digest that is
+// derived from the names and digests of its children. Symlinks are given a digest
+// of their target. File digests are not recomputed, so this can be used after
+// changing them with SetDigest.
+// If newHash is nil, DefaultHash is used.
+func (dir *Directory) ComputeDirectoryHashes(newHash func() hash.Hash) {
+	if newHash == nil {
+		newHash = DefaultHash
+	}
+	dir.merkle(newHash)
+}
+
+// ContentEquals determines if other has the same digest as the current Directory.
+// Because digests are Merkle digests, two Directories with the same digest have the
+// same names and contents all the way down. It returns false if either Directory
+// has not been hashed.
+func (dir Directory) ContentEquals(other *Directory) bool {
+	return dir.digest != nil && other.digest != nil && bytes.Equal(dir.digest, other.digest)
+}
+
+func (dir *Directory) merkle(newHash func() hash.Hash) []byte {
+	h := newHash()
+	for _, name := range sortedKeys(dir.subDirectories) {
+		writeMerkleEntry(h, 'd', name, dir.subDirectories[name].merkle(newHash))
+	}
+	for _, name := range sortedFileKeys(dir.files) {
+		writeMerkleEntry(h, 'f', name, dir.files[name].digest)
+	}
+	for _, name := range sortedSymlinkKeys(dir.symlinks) {
+		link := dir.symlinks[name]
+		targetHash := newHash()
+		_, _ = targetHash.Write([]byte(link.target))
+		link.digest = targetHash.Sum(nil)
+		writeMerkleEntry(h, 'l', name, link.digest)
+	}
+	dir.digest = h.Sum(nil)
+	return dir.digest
+}
+
+func writeMerkleEntry(h hash.Hash, kind byte, name string, digest []byte) {
+	_, _ = h.Write([]byte{kind})
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(digest)
+}
+
+func hashFile(fullPath string, h hash.Hash) ([]byte, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func sortedKeys(directories map[string]*Directory) []string {
+	keys := make([]string, 0, len(directories))
+	for name := range directories {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedFileKeys(files map[string]*File) []string {
+	keys := make([]string, 0, len(files))
+	for name := range files {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedSymlinkKeys(symlinks map[string]*Symlink) []string {
+	keys := make([]string, 0, len(symlinks))
+	for name := range symlinks {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package structure
+
+import (
+	"bytes"
+	"crypto/md5"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectory_ComputeDirectoryHashes_EqualForSameContents(t *testing.T) {
+	contents := map[string]string{"file1": "a", filepath.Join("sub1", "file2"): "b"}
+	dir1 := digestTree(filepath.Join(osRoot(), "tmp"), contents)
+	dir2 := digestTree(filepath.Join(osRoot(), "other"), contents)
+	if !dir1.ContentEquals(dir2) {
+		t.Fatal("directories had the same contents but digests did not match")
+	}
+	if !bytes.Equal(dir1.SubDirectory("sub1").Digest(), dir2.SubDirectory("sub1").Digest()) {
+		t.Fatal("subdirectories had the same contents but digests did not match")
+	}
+}
+
+func TestDirectory_ComputeDirectoryHashes_DifferentWhenFileDigestChanges(t *testing.T) {
+	dir1 := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{filepath.Join("sub1", "file1"): "a", "file2": "b"})
+	dir2 := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{filepath.Join("sub1", "file1"): "c", "file2": "b"})
+	if dir1.ContentEquals(dir2) {
+		t.Fatal("directories had different contents but digests matched")
+	}
+}
+
+func TestDirectory_ComputeDirectoryHashes_DifferentWhenNameChanges(t *testing.T) {
+	dir1 := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{"file1": "a"})
+	dir2 := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{"file2": "a"})
+	if dir1.ContentEquals(dir2) {
+		t.Fatal("directories had different names but digests matched")
+	}
+}
+
+func TestDirectory_ComputeDirectoryHashes_DistinguishesFileFromDirectory(t *testing.T) {
+	dir1 := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	_, err := dir1.AddDirectory(filepath.Join(osRoot(), "tmp", "dir1", "item"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir2 := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	_, err = dir2.AddFile(filepath.Join(osRoot(), "tmp", "dir1", "item"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir1.ComputeDirectoryHashes(nil)
+	dir2.ComputeDirectoryHashes(nil)
+	if dir1.ContentEquals(dir2) {
+		t.Fatal("a file and a directory with the same name had matching digests")
+	}
+}
+
+func TestDirectory_ComputeDirectoryHashes_UsesGivenHash(t *testing.T) {
+	dir := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{"file1": "a"})
+	dir.ComputeDirectoryHashes(md5.New)
+	if len(dir.Digest()) != md5.Size {
+		t.Fatalf("digest length was incorrect. expected: %d actual: %d", md5.Size, len(dir.Digest()))
+	}
+}
+
+func TestDirectory_ContentEquals_FalseWhenNotHashed(t *testing.T) {
+	dir1 := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	dir2 := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	if dir1.ContentEquals(dir2) {
+		t.Fatal("directories without digests were found to be equal")
+	}
+}
@@ -0,0 +1,323 @@
+package structure
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// The JSON schema of a Directory tree is nested. Every node is an object with
+//
+//	"name"        the name of the node
+//	"path"        the path to the node excluding the node itself. It is only
+//	              present on the node that was marshalled, the paths of nested
+//	              nodes follow from their parents.
+//	"metadata"    the Metadata of the node, if it has any
+//	"digest"      the hex encoded digest of the node, if it has been hashed
+//
+// Symlinks add "target", the target of the link. Directories add "linkTarget" if
+// they were reached through a symlink, "incomplete" if they are Incomplete, and
+// "directories", "files" and "symlinks", arrays of their children sorted by name
+// which are left out when empty. Names must not be empty, "." or ".." or contain a
+// separator, and must be unique within their Directory, or decoding fails.
+// Descendants is an object with the arrays "directories" and "files", and "symlinks"
+// which is left out when empty. Its Directories are marshalled without their
+// children since those are listed in the arrays themselves.
+
+type fileJSON struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path,omitempty"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+	Digest   string    `json:"digest,omitempty"`
+}
+
+type symlinkJSON struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path,omitempty"`
+	Target   string    `json:"target"`
+	Metadata *Metadata `json:"metadata,omitempty"`
+	Digest   string    `json:"digest,omitempty"`
+}
+
+type directoryJSON struct {
+	Name        string          `json:"name"`
+	Path        string          `json:"path,omitempty"`
+	LinkTarget  string          `json:"linkTarget,omitempty"`
+	Metadata    *Metadata       `json:"metadata,omitempty"`
+	Digest      string          `json:"digest,omitempty"`
+	Incomplete  bool            `json:"incomplete,omitempty"`
+	Directories []directoryJSON `json:"directories,omitempty"`
+	Files       []fileJSON      `json:"files,omitempty"`
+	Symlinks    []symlinkJSON   `json:"symlinks,omitempty"`
+}
+
+type descendantsJSON struct {
+	Directories []directoryJSON `json:"directories"`
+	Files       []fileJSON      `json:"files"`
+	Symlinks    []symlinkJSON   `json:"symlinks,omitempty"`
+}
+
+// MarshalJSON encodes the Directory and all of its descendants
+func (dir Directory) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dir.toJSON(true, true))
+}
+
+// UnmarshalJSON replaces the Directory with the tree encoded in data
+func (dir *Directory) UnmarshalJSON(data []byte) error {
+	var encoded directoryJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	if encoded.Name != "" {
+		if err := checkName("unmarshal", encoded.Path, encoded.Name); err != nil {
+			return err
+		}
+	}
+	decoded, err := encoded.toDirectory(encoded.Path)
+	if err != nil {
+		return err
+	}
+	*dir = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the File
+func (file File) MarshalJSON() ([]byte, error) {
+	return json.Marshal(file.toJSON(true))
+}
+
+// UnmarshalJSON replaces the File with the File encoded in data
+func (file *File) UnmarshalJSON(data []byte) error {
+	var encoded fileJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	if err := checkName("unmarshal", encoded.Path, encoded.Name); err != nil {
+		return err
+	}
+	decoded, err := encoded.toFile(encoded.Path)
+	if err != nil {
+		return err
+	}
+	*file = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the Symlink
+func (link Symlink) MarshalJSON() ([]byte, error) {
+	return json.Marshal(link.toJSON(true))
+}
+
+// UnmarshalJSON replaces the Symlink with the Symlink encoded in data
+func (link *Symlink) UnmarshalJSON(data []byte) error {
+	var encoded symlinkJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	if err := checkName("unmarshal", encoded.Path, encoded.Name); err != nil {
+		return err
+	}
+	decoded, err := encoded.toSymlink(encoded.Path)
+	if err != nil {
+		return err
+	}
+	*link = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the Directories, Files and Symlinks of the Descendants in order.
+// The Directories are encoded without their children.
+func (desc Descendants) MarshalJSON() ([]byte, error) {
+	encoded := descendantsJSON{Directories: []directoryJSON{}, Files: []fileJSON{}}
+	for _, dir := range desc.Directories {
+		encoded.Directories = append(encoded.Directories, dir.toJSON(true, false))
+	}
+	for _, file := range desc.Files {
+		encoded.Files = append(encoded.Files, file.toJSON(true))
+	}
+	for _, link := range desc.Symlinks {
+		encoded.Symlinks = append(encoded.Symlinks, link.toJSON(true))
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON replaces the Descendants with those encoded in data.
+// The Directories have no children.
+func (desc *Descendants) UnmarshalJSON(data []byte) error {
+	var encoded descendantsJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded := Descendants{}
+	for _, encodedDir := range encoded.Directories {
+		encodedDir.Directories, encodedDir.Files, encodedDir.Symlinks = nil, nil, nil
+		if err := checkName("unmarshal", encodedDir.Path, encodedDir.Name); err != nil {
+			return err
+		}
+		dir, err := encodedDir.toDirectory(encodedDir.Path)
+		if err != nil {
+			return err
+		}
+		decoded.Directories = append(decoded.Directories, dir)
+	}
+	for _, encodedFile := range encoded.Files {
+		if err := checkName("unmarshal", encodedFile.Path, encodedFile.Name); err != nil {
+			return err
+		}
+		file, err := encodedFile.toFile(encodedFile.Path)
+		if err != nil {
+			return err
+		}
+		decoded.Files = append(decoded.Files, file)
+	}
+	for _, encodedLink := range encoded.Symlinks {
+		if err := checkName("unmarshal", encodedLink.Path, encodedLink.Name); err != nil {
+			return err
+		}
+		link, err := encodedLink.toSymlink(encodedLink.Path)
+		if err != nil {
+			return err
+		}
+		decoded.Symlinks = append(decoded.Symlinks, link)
+	}
+	*desc = decoded
+	return nil
+}
+
+// toJSON converts the Directory to its JSON form. The path is only included if
+// withPath is set and the children only if recursive is set.
+func (dir Directory) toJSON(withPath bool, recursive bool) directoryJSON {
+	encoded := directoryJSON{
+		Name:       dir.name,
+		LinkTarget: dir.linkTarget,
+		Metadata:   dir.metadata,
+		Digest:     hex.EncodeToString(dir.digest),
+		Incomplete: dir.incomplete,
+	}
+	if withPath {
+		encoded.Path = dir.path
+	}
+	if !recursive {
+		return encoded
+	}
+	for _, name := range sortedKeys(dir.subDirectories) {
+		encoded.Directories = append(encoded.Directories, dir.subDirectories[name].toJSON(false, true))
+	}
+	for _, name := range sortedFileKeys(dir.files) {
+		encoded.Files = append(encoded.Files, dir.files[name].toJSON(false))
+	}
+	for _, name := range sortedSymlinkKeys(dir.symlinks) {
+		encoded.Symlinks = append(encoded.Symlinks, dir.symlinks[name].toJSON(false))
+	}
+	return encoded
+}
+
+func (file File) toJSON(withPath bool) fileJSON {
+	encoded := fileJSON{Name: file.name, Metadata: file.metadata, Digest: hex.EncodeToString(file.digest)}
+	if withPath {
+		encoded.Path = file.path
+	}
+	return encoded
+}
+
+func (link Symlink) toJSON(withPath bool) symlinkJSON {
+	encoded := symlinkJSON{Name: link.name, Target: link.target, Metadata: link.metadata, Digest: hex.EncodeToString(link.digest)}
+	if withPath {
+		encoded.Path = link.path
+	}
+	return encoded
+}
+
+// toDirectory converts the JSON form of a Directory at path back into a Directory tree.
+// It returns an error if a descendant has an invalid name or shares its name with a sibling.
+func (encoded directoryJSON) toDirectory(path string) (*Directory, error) {
+	dir := NewDirectory(encoded.Name, path)
+	names := childNames{op: "unmarshal", path: dir.FullPath()}
+	for _, encodedDir := range encoded.Directories {
+		if err := names.add(encodedDir.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, encodedFile := range encoded.Files {
+		if err := names.add(encodedFile.Name); err != nil {
+			return nil, err
+		}
+	}
+	for _, encodedLink := range encoded.Symlinks {
+		if err := names.add(encodedLink.Name); err != nil {
+			return nil, err
+		}
+	}
+	dir.linkTarget = encoded.LinkTarget
+	dir.incomplete = encoded.Incomplete
+	dir.metadata = encoded.Metadata
+	digest, err := decodeDigest(encoded.Name, encoded.Digest)
+	if err != nil {
+		return nil, err
+	}
+	dir.digest = digest
+	for _, encodedDir := range encoded.Directories {
+		subDir, err := encodedDir.toDirectory(dir.FullPath())
+		if err != nil {
+			return nil, err
+		}
+		if dir.subDirectories == nil {
+			dir.subDirectories = map[string]*Directory{}
+		}
+		dir.subDirectories[subDir.name] = subDir
+	}
+	for _, encodedFile := range encoded.Files {
+		file, err := encodedFile.toFile(dir.FullPath())
+		if err != nil {
+			return nil, err
+		}
+		if dir.files == nil {
+			dir.files = map[string]*File{}
+		}
+		dir.files[file.name] = file
+	}
+	for _, encodedLink := range encoded.Symlinks {
+		link, err := encodedLink.toSymlink(dir.FullPath())
+		if err != nil {
+			return nil, err
+		}
+		if dir.symlinks == nil {
+			dir.symlinks = map[string]*Symlink{}
+		}
+		dir.symlinks[link.name] = link
+	}
+	return dir, nil
+}
+
+func (encoded fileJSON) toFile(path string) (*File, error) {
+	digest, err := decodeDigest(encoded.Name, encoded.Digest)
+	if err != nil {
+		return nil, err
+	}
+	file := NewFile(encoded.Name, path)
+	file.metadata = encoded.Metadata
+	file.digest = digest
+	return &file, nil
+}
+
+func (encoded symlinkJSON) toSymlink(path string) (*Symlink, error) {
+	digest, err := decodeDigest(encoded.Name, encoded.Digest)
+	if err != nil {
+		return nil, err
+	}
+	link := NewSymlink(encoded.Name, path, encoded.Target)
+	link.metadata = encoded.Metadata
+	link.digest = digest
+	return &link, nil
+}
+
+func decodeDigest(name string, encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	digest, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("digest of '%s' is not valid hex: %v", name, err)
+	}
+	return digest, nil
+}
@@ -0,0 +1,166 @@
+package structure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectory_MarshalJSON(t *testing.T) {
+	actual, err := json.Marshal(encodingTree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, _ := json.Marshal(filepath.Join(osRoot(), "tmp"))
+	expected := fmt.Sprintf(`{"name":"dir1","path":%s,`+
+		`"metadata":{"size":4096,"mode":2147484141,"modTime":"2020-01-02T03:04:05Z"},`+
+		`"directories":[{"name":"sub1","files":[{"name":"file1",`+
+		`"metadata":{"size":3,"mode":420,"modTime":"2020-01-02T03:04:05Z","uid":1000},"digest":"abcd"}]}],`+
+		`"files":[{"name":"file2"}],"symlinks":[{"name":"link","target":"file2"}]}`, path)
+	if string(actual) != expected {
+		t.Fatalf("json was incorrect.\nexpected: %s\nactual:   %s", expected, actual)
+	}
+}
+
+func TestDirectory_UnmarshalJSON_RoundTrips(t *testing.T) {
+	expected := encodingTree()
+	data, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual Directory
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if !actual.StructureEquals(expected) {
+		t.Fatal("unmarshalled directory structure did not match the original")
+	}
+	if !actual.Metadata().Equals(expected.Metadata()) {
+		t.Fatalf("directory metadata was incorrect. expected: %v actual: %v", expected.Metadata(), actual.Metadata())
+	}
+	file := actual.SubDirectory("sub1").File("file1")
+	expectedFile := expected.SubDirectory("sub1").File("file1")
+	if file.FullPath() != expectedFile.FullPath() {
+		t.Fatalf("file path was incorrect. expected: %s actual: %s", expectedFile.FullPath(), file.FullPath())
+	}
+	if !bytes.Equal(file.Digest(), expectedFile.Digest()) || !file.Metadata().Equals(expectedFile.Metadata()) {
+		t.Fatal("file digest or metadata did not survive the round trip")
+	}
+	again, err := json.Marshal(&actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Fatalf("json was not stable.\nfirst:  %s\nsecond: %s", data, again)
+	}
+}
+
+func TestDirectory_JSONKeepsIncomplete(t *testing.T) {
+	expected := encodingTree()
+	expected.SubDirectory("sub1").incomplete = true
+	data, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`{"name":"sub1","incomplete":true,`)) {
+		t.Fatalf("json did not mark sub1 as incomplete: %s", data)
+	}
+	var actual Directory
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if actual.Incomplete() || !actual.SubDirectory("sub1").Incomplete() {
+		t.Fatal("incomplete flag did not survive the round trip")
+	}
+}
+
+func TestDirectory_UnmarshalJSON_ReturnsErrorForInvalidNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected error
+	}{
+		{"ParentDirectory", `{"name":"root","path":"/tmp","directories":[{"name":".."}]}`, ErrInvalidName},
+		{"CurrentDirectory", `{"name":"root","path":"/tmp","directories":[{"name":"."}]}`, ErrInvalidName},
+		{"EmptyFile", `{"name":"root","path":"/tmp","files":[{"name":""}]}`, ErrInvalidName},
+		{"FileWithSeparator", `{"name":"root","path":"/tmp","files":[{"name":"a/../b"}]}`, ErrInvalidName},
+		{"SymlinkWithSeparator", `{"name":"root","path":"/tmp","symlinks":[{"name":"../../x","target":"y"}]}`, ErrInvalidName},
+		{"NestedEscape", `{"name":"root","path":"/tmp","directories":[{"name":"a","files":[{"name":"../../escaped"}]}]}`, ErrInvalidName},
+		{"Root", `{"name":"..","path":"/tmp"}`, ErrInvalidName},
+		{"DuplicateFiles", `{"name":"root","path":"/tmp","files":[{"name":"a"},{"name":"a"}]}`, ErrNameConflict},
+		{"DuplicateAcrossTypes", `{"name":"root","path":"/tmp","directories":[{"name":"a"}],"symlinks":[{"name":"a","target":"b"}]}`, ErrNameConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dir Directory
+			if err := json.Unmarshal([]byte(tt.json), &dir); !errors.Is(err, tt.expected) {
+				t.Fatalf("expected %v but got %v", tt.expected, err)
+			}
+		})
+	}
+	var file File
+	if err := json.Unmarshal([]byte(`{"name":"..","path":"/tmp"}`), &file); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName for a file but got %v", err)
+	}
+	var desc Descendants
+	if err := json.Unmarshal([]byte(`{"directories":[],"files":[{"name":"a/b","path":"/tmp"}]}`), &desc); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName for descendants but got %v", err)
+	}
+}
+
+func TestDirectory_UnmarshalJSON_ReturnsErrorForInvalidDigest(t *testing.T) {
+	var dir Directory
+	if err := json.Unmarshal([]byte(`{"name":"dir1","path":"/tmp","files":[{"name":"file1","digest":"xyz"}]}`), &dir); err == nil {
+		t.Fatal("error should have been returned but was nil")
+	}
+}
+
+func TestFile_JSONRoundTrips(t *testing.T) {
+	expected := NewFile("file1", filepath.Join(osRoot(), "tmp"))
+	expected.SetDigest([]byte{1, 2, 3})
+	data, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual File
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if !actual.Equals(&expected) || !bytes.Equal(actual.Digest(), expected.Digest()) {
+		t.Fatalf("file did not survive the round trip: %s", data)
+	}
+}
+
+func TestDescendants_JSONRoundTrips(t *testing.T) {
+	dir := encodingTree()
+	expected := Descendants{
+		Directories: []*Directory{dir.SubDirectory("sub1")},
+		Files:       []*File{dir.File("file2"), dir.SubDirectory("sub1").File("file1")},
+		Symlinks:    []*Symlink{dir.Symlink("link")},
+	}
+	data, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual Descendants
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if len(actual.Directories) != 1 || actual.Directories[0].FullPath() != expected.Directories[0].FullPath() {
+		t.Fatalf("directories were incorrect: %s", data)
+	}
+	if len(actual.Directories[0].Files()) != 0 {
+		t.Fatal("descendant directories should not include their children")
+	}
+	for i, file := range expected.Files {
+		if !actual.Files[i].Equals(file) {
+			t.Fatalf("file %d was incorrect. expected: %s actual: %s", i, file.FullPath(), actual.Files[i].FullPath())
+		}
+	}
+	if len(actual.Symlinks) != 1 || !actual.Symlinks[0].Equals(expected.Symlinks[0]) || actual.Symlinks[0].Target() != "file2" {
+		t.Fatalf("symlinks were incorrect: %s", data)
+	}
+}
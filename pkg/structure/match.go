@@ -0,0 +1,158 @@
+package structure
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// wildmatch determines if name matches pattern. Both use '/' as the separator.
+// '*' matches any run of characters except '/', '?' matches any single character
+// except '/' and '[...]' matches a character class, negated by a leading '!' or '^'.
+// A "**" segment matches zero or more whole segments. A backslash escapes the next
+// character. Malformed patterns never match.
+func wildmatch(pattern string, name string) bool {
+	return matchFrom(pattern, 0, name)
+}
+
+// matchFrom matches name against pattern[p:]. The whole pattern is kept so that
+// "**" can be recognised only when it starts a segment.
+func matchFrom(pattern string, p int, name string) bool {
+	for p < len(pattern) {
+		switch pattern[p] {
+		case '*':
+			if isDoubleStar(pattern, p) {
+				rest := p + 2
+				if rest == len(pattern) {
+					return true
+				}
+				rest++
+				for {
+					if matchFrom(pattern, rest, name) {
+						return true
+					}
+					slash := strings.IndexByte(name, '/')
+					if slash < 0 {
+						return false
+					}
+					name = name[slash+1:]
+				}
+			}
+			for p < len(pattern) && pattern[p] == '*' {
+				p++
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchFrom(pattern, p, name[i:]) {
+					return true
+				}
+				if i < len(name) && name[i] == '/' {
+					return false
+				}
+			}
+			return false
+		case '?':
+			r, size := utf8.DecodeRuneInString(name)
+			if size == 0 || r == '/' {
+				return false
+			}
+			p, name = p+1, name[size:]
+		case '[':
+			r, size := utf8.DecodeRuneInString(name)
+			if size == 0 || r == '/' {
+				return false
+			}
+			matched, classSize, ok := matchClass(pattern[p:], r)
+			if !ok || !matched {
+				return false
+			}
+			p, name = p+classSize, name[size:]
+		case '\\':
+			if p+1 >= len(pattern) || len(name) == 0 || name[0] != pattern[p+1] {
+				return false
+			}
+			p, name = p+2, name[1:]
+		default:
+			if len(name) == 0 || name[0] != pattern[p] {
+				return false
+			}
+			p, name = p+1, name[1:]
+		}
+	}
+	return len(name) == 0
+}
+
+// isDoubleStar determines if the "*" at pattern[p] starts a whole "**" segment
+func isDoubleStar(pattern string, p int) bool {
+	return strings.HasPrefix(pattern[p:], "**") &&
+		(p == 0 || pattern[p-1] == '/') &&
+		(p+2 == len(pattern) || pattern[p+2] == '/')
+}
+
+// matchClass matches r against the character class at the start of pattern.
+// It returns whether r matched, the length of the class in pattern and false
+// if the class is not terminated.
+func matchClass(pattern string, r rune) (matched bool, size int, ok bool) {
+	i := 1
+	negated := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negated = true
+		i++
+	}
+	first := true
+	for i < len(pattern) {
+		if pattern[i] == ']' && !first {
+			return matched != negated, i + 1, true
+		}
+		first = false
+		lo, loSize := classChar(pattern[i:])
+		if loSize == 0 {
+			return false, 0, false
+		}
+		i += loSize
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			var hiSize int
+			hi, hiSize = classChar(pattern[i+1:])
+			if hiSize == 0 {
+				return false, 0, false
+			}
+			i += 1 + hiSize
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	return false, 0, false
+}
+
+func classChar(pattern string) (rune, int) {
+	if pattern[0] == '\\' {
+		if len(pattern) < 2 {
+			return 0, 0
+		}
+		r, size := utf8.DecodeRuneInString(pattern[1:])
+		return r, size + 1
+	}
+	return utf8.DecodeRuneInString(pattern)
+}
+
+// validatePattern returns filepath.ErrBadPattern if pattern contains an
+// unterminated character class or ends in a lone backslash
+func validatePattern(pattern string) error {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i+1 >= len(pattern) {
+				return filepath.ErrBadPattern
+			}
+			i++
+		case '[':
+			_, size, ok := matchClass(pattern[i:], 0)
+			if !ok {
+				return filepath.ErrBadPattern
+			}
+			i += size - 1
+		}
+	}
+	return nil
+}
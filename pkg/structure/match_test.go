@@ -0,0 +1,63 @@
+package structure
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var wildmatchTests = []struct {
+	pattern string
+	name    string
+	matches bool
+}{
+	{"foo", "foo", true},
+	{"foo", "foobar", false},
+	{"*.go", "main.go", true},
+	{"*.go", "cmd/main.go", false},
+	{"?ain.go", "main.go", true},
+	{"?", "/", false},
+	{"[mn]ain.go", "main.go", true},
+	{"[!m]ain.go", "main.go", false},
+	{"[^m]ain.go", "nain.go", true},
+	{"[a-c]", "b", true},
+	{"[a-c]", "d", false},
+	{"[]]", "]", true},
+	{"[", "[", false},
+	{`\*`, "*", true},
+	{`\*`, "a", false},
+	{"**", "a/b/c", true},
+	{"**/foo", "foo", true},
+	{"**/foo", "a/b/foo", true},
+	{"a/**/b", "a/b", true},
+	{"a/**/b", "a/x/y/b", true},
+	{"a/**", "a/x/y", true},
+	{"a/**", "a", false},
+	{"a**b", "axxb", true},
+	{"a**b", "ax/xb", false},
+	{"src/**/*_test.go", "src/pkg/structure/file_test.go", true},
+	{"src/**/*_test.go", "src/file_test.go", true},
+	{"src/**/*_test.go", "src/file.go", false},
+}
+
+func TestWildmatch(t *testing.T) {
+	for _, tt := range wildmatchTests {
+		t.Run(tt.pattern+"_"+tt.name, func(t *testing.T) {
+			if actual := wildmatch(tt.pattern, tt.name); actual != tt.matches {
+				t.Fatalf("wildmatch('%s', '%s') was %t but expected %t", tt.pattern, tt.name, actual, tt.matches)
+			}
+		})
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	for _, pattern := range []string{"*.go", "[a-z]", "[]]", `\*`, "**/x"} {
+		if err := validatePattern(pattern); err != nil {
+			t.Fatalf("pattern '%s' was valid but returned %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"[a-z", `foo\`, "[!"} {
+		if err := validatePattern(pattern); err != filepath.ErrBadPattern {
+			t.Fatalf("pattern '%s' was invalid but returned %v", pattern, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package structure
+
+import (
+	"os"
+	"time"
+)
+
+// Metadata holds the information about a File or Directory that was
+// captured from the filesystem when the tree was scanned
+type Metadata struct {
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"modTime"`
+	Uid     uint32      `json:"uid,omitempty"`
+	Gid     uint32      `json:"gid,omitempty"`
+	Inode   uint64      `json:"inode,omitempty"`
+	Device  uint64      `json:"device,omitempty"`
+}
+
+// NewMetadata creates a new Metadata from the os.FileInfo of a File or Directory.
+// Owner, inode and device are only filled in on platforms that provide them
+func NewMetadata(info os.FileInfo) *Metadata {
+	metadata := &Metadata{
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}
+	fillSysMetadata(metadata, info)
+	return metadata
+}
+
+// Equals determines if other describes the same size, mode, modification time,
+// owner and identity as the current Metadata
+func (metadata Metadata) Equals(other *Metadata) bool {
+	return other != nil &&
+		metadata.Size == other.Size &&
+		metadata.Mode == other.Mode &&
+		metadata.ModTime.Equal(other.ModTime) &&
+		metadata.Uid == other.Uid &&
+		metadata.Gid == other.Gid &&
+		metadata.Inode == other.Inode &&
+		metadata.Device == other.Device
+}
@@ -0,0 +1,8 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package structure
+
+import "os"
+
+func fillSysMetadata(metadata *Metadata, info os.FileInfo) {}
@@ -0,0 +1,53 @@
+package structure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMetadata_Equals_TrueWithIdentity(t *testing.T) {
+	metadata := Metadata{Size: 10, Mode: 0644, ModTime: time.Unix(1000, 0), Uid: 1, Gid: 2, Inode: 3, Device: 4}
+	if !metadata.Equals(&metadata) {
+		t.Fatal("metadata was equal but was not found to be")
+	}
+}
+
+func TestMetadata_Equals_FalseWhenNil(t *testing.T) {
+	metadata := Metadata{Size: 10}
+	if metadata.Equals(nil) {
+		t.Fatal("metadata was found to be equal to nil")
+	}
+}
+
+func TestMetadata_Equals_FalseWhenDifferent(t *testing.T) {
+	metadata1 := Metadata{Size: 10, Mode: 0644, ModTime: time.Unix(1000, 0)}
+	metadata2 := Metadata{Size: 10, Mode: 0600, ModTime: time.Unix(1000, 0)}
+	if metadata1.Equals(&metadata2) {
+		t.Fatal("metadata was found to be equal but was not")
+	}
+}
+
+func TestFile_Metadata_NilByDefault(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	file, err := dir.AddFile(filepath.Join(osRoot(), "tmp", "dir1", "file1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Metadata() != nil {
+		t.Fatal("metadata should have been nil")
+	}
+	if dir.Metadata() != nil {
+		t.Fatal("metadata should have been nil")
+	}
+}
+
+func TestFile_SetMetadata(t *testing.T) {
+	file := NewFile("file1", filepath.Join(osRoot(), "tmp"))
+	metadata := &Metadata{Size: 42, Mode: os.FileMode(0600)}
+	file.SetMetadata(metadata)
+	if file.Metadata() != metadata {
+		t.Fatal("metadata was not set")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package structure
+
+import (
+	"os"
+	"syscall"
+)
+
+func fillSysMetadata(metadata *Metadata, info os.FileInfo) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	metadata.Uid = stat.Uid
+	metadata.Gid = stat.Gid
+	metadata.Inode = uint64(stat.Ino)
+	metadata.Device = uint64(stat.Dev)
+}
@@ -0,0 +1,96 @@
+package structure
+
+import "sort"
+
+// NodeType describes what kind of entry a node in a Directory tree is
+type NodeType int
+
+const (
+	// FileNode is the NodeType of a File
+	FileNode NodeType = iota
+	// DirectoryNode is the NodeType of a Directory
+	DirectoryNode
+	// SymlinkNode is the NodeType of a Symlink
+	SymlinkNode
+)
+
+func (nodeType NodeType) String() string {
+	switch nodeType {
+	case FileNode:
+		return "file"
+	case DirectoryNode:
+		return "directory"
+	case SymlinkNode:
+		return "symlink"
+	default:
+		return "unknown"
+	}
+}
+
+// Node is implemented by every kind of entry that can be stored in a Directory tree
+type Node interface {
+	Name() string
+	Path() string
+	FullPath() string
+	Metadata() *Metadata
+	Digest() []byte
+	Type() NodeType
+}
+
+// children returns every File, Directory and Symlink directly inside dir by name
+func (dir Directory) children() map[string]Node {
+	children := make(map[string]Node, len(dir.files)+len(dir.subDirectories)+len(dir.symlinks))
+	for name, file := range dir.files {
+		children[name] = file
+	}
+	for name, link := range dir.symlinks {
+		children[name] = link
+	}
+	for name, subDir := range dir.subDirectories {
+		children[name] = subDir
+	}
+	return children
+}
+
+// childrenByName returns every File, Directory and Symlink directly inside dir grouped by
+// name. Unlike children, it keeps entries of different NodeTypes that share a name.
+// Each group lists its Files, Directories and Symlinks in that order.
+func (dir Directory) childrenByName() map[string][]Node {
+	children := make(map[string][]Node, len(dir.files)+len(dir.subDirectories)+len(dir.symlinks))
+	for name, file := range dir.files {
+		children[name] = append(children[name], file)
+	}
+	for name, subDir := range dir.subDirectories {
+		children[name] = append(children[name], subDir)
+	}
+	for name, link := range dir.symlinks {
+		children[name] = append(children[name], link)
+	}
+	return children
+}
+
+// sortedChildren returns every File, Directory and Symlink directly inside dir sorted
+// by name, and entries that share a name by NodeType
+func (dir Directory) sortedChildren() []Node {
+	byName := dir.childrenByName()
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	children := make([]Node, 0, len(dir.files)+len(dir.subDirectories)+len(dir.symlinks))
+	for _, name := range names {
+		children = append(children, byName[name]...)
+	}
+	return children
+}
+
+// childOfType returns the entry in nodes with nodeType, or nil if there is none
+func childOfType(nodes []Node, nodeType NodeType) Node {
+	for _, node := range nodes {
+		if node.Type() == nodeType {
+			return node
+		}
+	}
+	return nil
+}
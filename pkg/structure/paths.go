@@ -0,0 +1,83 @@
+package structure
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SafeJoin joins elements onto base like filepath.Join, but returns an error if the
+// result is not base or inside it, such as when an element climbs out with "..".
+// Containment is decided on whole path segments, so "/tmp/dir10" is not inside "/tmp/dir1".
+func SafeJoin(base string, elements ...string) (string, error) {
+	if base == "" {
+		base = "."
+	}
+	joined := filepath.Join(append([]string{base}, elements...)...)
+	if _, ok := relativeSegments(base, joined); !ok {
+		return "", newPathError("join", joined, ErrNotSubPath, "'%s' is not inside '%s'", joined, filepath.Clean(base))
+	}
+	return joined, nil
+}
+
+// pathSegments cleans path and splits it into its segments. The volume name and
+// the leading separator of an absolute path form the first segment.
+func pathSegments(path string) []string {
+	path = filepath.Clean(path)
+	volume := filepath.VolumeName(path)
+	rest := path[len(volume):]
+	var segments []string
+	switch {
+	case strings.HasPrefix(rest, string(os.PathSeparator)):
+		segments = append(segments, volume+string(os.PathSeparator))
+		rest = rest[1:]
+	case volume != "":
+		segments = append(segments, volume)
+	}
+	if rest != "" && rest != "." {
+		segments = append(segments, strings.Split(rest, string(os.PathSeparator))...)
+	}
+	return segments
+}
+
+// relativeSegments returns the segments of path below base. It returns false if
+// path, once cleaned, is neither base nor inside it. A relative path is compared
+// with an absolute base as if it started at the root, as relative trees do.
+func relativeSegments(base string, path string) ([]string, bool) {
+	baseSegments, segments := pathSegments(base), pathSegments(path)
+	if filepath.IsAbs(base) != filepath.IsAbs(path) && filepath.VolumeName(base) == filepath.VolumeName(path) {
+		baseSegments, segments = withoutRoot(baseSegments), withoutRoot(segments)
+	}
+	if len(segments) < len(baseSegments) {
+		return nil, false
+	}
+	for i, segment := range baseSegments {
+		if segments[i] != segment {
+			return nil, false
+		}
+	}
+	rest := segments[len(baseSegments):]
+	if len(rest) > 0 && rest[0] == ".." {
+		return nil, false
+	}
+	return rest, true
+}
+
+// resolve cleans fullPath, which must be strictly below the current Directory, and splits
+// it into the names of the Directories between the current Directory and the entry and
+// the name of the entry. It returns false if fullPath is not below the current Directory.
+func (dir *Directory) resolve(fullPath string) ([]string, string, bool) {
+	segments, ok := relativeSegments(dir.FullPath(), fullPath)
+	if !ok || len(segments) == 0 {
+		return nil, "", false
+	}
+	return segments[:len(segments)-1], segments[len(segments)-1], true
+}
+
+// withoutRoot drops the root segment of an absolute path's segments
+func withoutRoot(segments []string) []string {
+	if len(segments) > 0 && strings.HasSuffix(segments[0], string(os.PathSeparator)) {
+		return segments[1:]
+	}
+	return segments
+}
@@ -0,0 +1,156 @@
+package structure
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var trickyPaths = []struct {
+	name   string
+	path   string
+	inside bool
+}{
+	{"itself", "tmp/dir1", true},
+	{"itself with trailing separator", "tmp/dir1/", true},
+	{"child", "tmp/dir1/x", true},
+	{"sibling with shared prefix", "tmp/dir10", false},
+	{"child of sibling with shared prefix", "tmp/dir10/x", false},
+	{"sibling with suffix", "tmp/dir1x", false},
+	{"escape through parent", "tmp/dir1/../dir10/x", false},
+	{"escape and return", "tmp/dir1/x/../../dir1/y", true},
+	{"parent through dot dot", "tmp/dir1/..", false},
+	{"dot segment", "tmp/dir1/./x", true},
+	{"doubled separators", "/tmp//dir1//x", true},
+	{"three dots is a name", "tmp/dir1/.../x", true},
+	{"parent", "tmp", false},
+	{"root", "", false},
+}
+
+func TestDirectory_IsSubPath_TrickyPaths(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	for _, tt := range trickyPaths {
+		t.Run(tt.name, func(t *testing.T) {
+			path := osRoot() + filepath.FromSlash(tt.path)
+			if actual := dir.IsSubPath(path); actual != tt.inside {
+				t.Fatalf("IsSubPath('%s') = %t, expected %t", path, actual, tt.inside)
+			}
+		})
+	}
+}
+
+func TestDirectory_IsSubPath_RandomPaths(t *testing.T) {
+	segments := []string{"tmp", "dir1", "dir10", "dir1x", "x", ".", "..", "...", ""}
+	base := filepath.Join(osRoot(), "tmp", "dir1")
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		parts := make([]string, random.Intn(7))
+		for j := range parts {
+			parts[j] = segments[random.Intn(len(segments))]
+		}
+		path := osRoot() + strings.Join(parts, string(os.PathSeparator))
+		rel, err := filepath.Rel(base, filepath.Clean(path))
+		expected := err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+		if actual := dir.IsSubPath(path); actual != expected {
+			t.Fatalf("IsSubPath('%s') = %t, expected %t", path, actual, expected)
+		}
+	}
+}
+
+func TestDirectory_AddFile_ResolvesParentSegments(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	root := dir.FullPath()
+	file, err := dir.AddFile(filepath.Join(root, "sub1") + string(os.PathSeparator) + filepath.Join("..", "sub2", "file1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.SubDirectory("sub1") != nil || dir.SubDirectory("sub2").File("file1") != file {
+		t.Fatal("file was not added at the resolved path")
+	}
+	if file.FullPath() != filepath.Join(root, "sub2", "file1") {
+		t.Fatalf("file has path '%s'", file.FullPath())
+	}
+	found, err := dir.GetFile(filepath.Join(root, "sub2") + string(os.PathSeparator) + filepath.Join(".", "file1"))
+	if err != nil || found != file {
+		t.Fatal("file was not found at an unclean path")
+	}
+}
+
+func TestDirectory_AddDirectory_RejectsEscapingPaths(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	for _, path := range []string{
+		filepath.Join(osRoot(), "tmp", "dir10", "x"),
+		dir.FullPath() + string(os.PathSeparator) + filepath.Join("..", "dir10"),
+		dir.FullPath(),
+	} {
+		if _, err := dir.AddDirectory(path); err == nil {
+			t.Fatalf("'%s' was added to '%s'", path, dir.FullPath())
+		}
+	}
+	if len(dir.SubDirectories()) != 0 {
+		t.Fatal("rejected paths created directories")
+	}
+}
+
+func TestDirectory_GetDirectory_RejectsSiblingWithSharedPrefix(t *testing.T) {
+	parent := NewDirectory("tmp", osRoot())
+	dir, err := parent.AddDirectory(filepath.Join(osRoot(), "tmp", "dir1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parent.AddDirectory(filepath.Join(osRoot(), "tmp", "dir10", "x")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dir.GetDirectory(filepath.Join(osRoot(), "tmp", "dir10", "x")); err == nil {
+		t.Fatal("found a directory outside of the current directory")
+	}
+	found, err := dir.GetDirectory(filepath.Join(osRoot(), "tmp", "dir1") + string(os.PathSeparator))
+	if err != nil || !found.Equals(dir) {
+		t.Fatal("did not find the directory itself")
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	base := filepath.Join(osRoot(), "srv", "data")
+	tests := []struct {
+		name     string
+		elements []string
+		expected string
+	}{
+		{"child", []string{"a", "b"}, filepath.Join(base, "a", "b")},
+		{"nothing", nil, base},
+		{"climb and return", []string{"a", "..", "..", "data", "b"}, filepath.Join(base, "b")},
+		{"absolute element stays inside", []string{string(os.PathSeparator) + "etc"}, filepath.Join(base, "etc")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := SafeJoin(base, tt.elements...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.expected {
+				t.Fatalf("expected '%s' but got '%s'", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSafeJoin_RejectsTraversal(t *testing.T) {
+	base := filepath.Join(osRoot(), "srv", "data")
+	for _, elements := range [][]string{
+		{".."},
+		{"a", "..", ".."},
+		{"..", "data10"},
+		{"..", "..", "etc", "passwd"},
+	} {
+		if joined, err := SafeJoin(base, elements...); err == nil {
+			t.Fatalf("joining %v onto '%s' gave '%s'", elements, base, joined)
+		}
+	}
+	if _, err := SafeJoin("", "..", "x"); err == nil {
+		t.Fatal("a relative join climbed out of its base")
+	}
+}
@@ -0,0 +1,189 @@
+package structure
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Predicate decides whether a node matches a Query. depth is the number of levels
+// the node is below the Directory the Query is run on, so direct children have depth 1.
+type Predicate func(node Node, depth int) bool
+
+// NameMatches matches nodes whose names match expression
+func NameMatches(expression *regexp.Regexp) Predicate {
+	return func(node Node, depth int) bool { return expression.MatchString(node.Name()) }
+}
+
+// Extension matches nodes whose names end in extension, including the dot
+func Extension(extension string) Predicate {
+	return func(node Node, depth int) bool { return filepath.Ext(node.Name()) == extension }
+}
+
+// OfType matches nodes of nodeType
+func OfType(nodeType NodeType) Predicate {
+	return func(node Node, depth int) bool { return node.Type() == nodeType }
+}
+
+// SizeBetween matches nodes whose size is at least min and at most max bytes.
+// Nodes without Metadata never match.
+func SizeBetween(min int64, max int64) Predicate {
+	return func(node Node, depth int) bool {
+		metadata := node.Metadata()
+		return metadata != nil && metadata.Size >= min && metadata.Size <= max
+	}
+}
+
+// ModifiedBetween matches nodes modified no earlier than from and no later than to.
+// Nodes without Metadata never match.
+func ModifiedBetween(from time.Time, to time.Time) Predicate {
+	return func(node Node, depth int) bool {
+		metadata := node.Metadata()
+		return metadata != nil && !metadata.ModTime.Before(from) && !metadata.ModTime.After(to)
+	}
+}
+
+// DepthBetween matches nodes at least min and at most max levels below the
+// Directory the Query is run on
+func DepthBetween(min int, max int) Predicate {
+	return func(node Node, depth int) bool { return depth >= min && depth <= max }
+}
+
+// HasPermissions matches nodes that have every permission bit in perm set.
+// Nodes without Metadata never match.
+func HasPermissions(perm os.FileMode) Predicate {
+	return func(node Node, depth int) bool {
+		metadata := node.Metadata()
+		return metadata != nil && metadata.Mode.Perm()&perm == perm
+	}
+}
+
+// OwnedBy matches nodes owned by the user uid.
+// Nodes without Metadata never match.
+func OwnedBy(uid uint32) Predicate {
+	return func(node Node, depth int) bool {
+		metadata := node.Metadata()
+		return metadata != nil && metadata.Uid == uid
+	}
+}
+
+// GroupOwnedBy matches nodes owned by the group gid.
+// Nodes without Metadata never match.
+func GroupOwnedBy(gid uint32) Predicate {
+	return func(node Node, depth int) bool {
+		metadata := node.Metadata()
+		return metadata != nil && metadata.Gid == gid
+	}
+}
+
+// And matches nodes that match every one of predicates
+func And(predicates ...Predicate) Predicate {
+	return func(node Node, depth int) bool {
+		for _, predicate := range predicates {
+			if !predicate(node, depth) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches nodes that match any of predicates
+func Or(predicates ...Predicate) Predicate {
+	return func(node Node, depth int) bool {
+		for _, predicate := range predicates {
+			if predicate(node, depth) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches nodes that do not match predicate
+func Not(predicate Predicate) Predicate {
+	return func(node Node, depth int) bool { return !predicate(node, depth) }
+}
+
+// Query describes which nodes Find returns. It is built with NewQuery
+// and refined with Prune and Limit.
+type Query struct {
+	match Predicate
+	prune Predicate
+	limit int
+}
+
+// NewQuery creates a Query that returns every node that matches match.
+// A nil match matches every node.
+func NewQuery(match Predicate) Query {
+	return Query{match: match}
+}
+
+// Prune returns a copy of the Query that does not descend into Directories that
+// match predicate. Those Directories can still be returned themselves.
+func (query Query) Prune(predicate Predicate) Query {
+	query.prune = predicate
+	return query
+}
+
+// Limit returns a copy of the Query that stops after limit nodes have been found.
+// Zero means no limit.
+func (query Query) Limit(limit int) Query {
+	query.limit = limit
+	return query
+}
+
+// Find searches the descendants of the current Directory depth first, visiting the
+// entries of each Directory in order of name, and returns every node that matches query.
+func (dir *Directory) Find(query Query) []Node {
+	matches, _ := dir.FindContext(context.Background(), query)
+	return matches
+}
+
+// FindContext works like Find but stops once ctx is done. It then returns
+// the nodes found so far along with ctx.Err().
+func (dir *Directory) FindContext(ctx context.Context, query Query) ([]Node, error) {
+	var matches []Node
+	err := dir.find(ctx, query, 1, &matches)
+	if err == errLimitReached {
+		err = nil
+	}
+	return matches, err
+}
+
+// errLimitReached stops find once the limit of a Query has been reached
+var errLimitReached = errors.New("limit reached")
+
+// find adds the matching descendants of dir to matches
+func (dir *Directory) find(ctx context.Context, query Query, depth int, matches *[]Node) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	children := dir.children()
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node := children[name]
+		if query.match == nil || query.match(node, depth) {
+			*matches = append(*matches, node)
+			if query.limit > 0 && len(*matches) >= query.limit {
+				return errLimitReached
+			}
+		}
+		subDir, ok := node.(*Directory)
+		if !ok || (query.prune != nil && query.prune(node, depth)) {
+			continue
+		}
+		if err := subDir.find(ctx, query, depth+1, matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
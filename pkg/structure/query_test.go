@@ -0,0 +1,76 @@
+package structure
+
+import (
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var queryMetadata = map[string]Metadata{
+	"main.go":                            {Size: 100, Mode: 0644, ModTime: time.Unix(100, 0), Uid: 1},
+	"script.sh":                          {Size: 10, Mode: 0755, ModTime: time.Unix(200, 0), Uid: 2},
+	filepath.Join("pkg", "util.go"):      {Size: 2000, Mode: 0644, ModTime: time.Unix(300, 0), Uid: 1},
+	filepath.Join("vendor", "lib.go"):    {Size: 50, Mode: 0644, ModTime: time.Unix(400, 0), Uid: 1},
+	filepath.Join("pkg", "a", "deep.go"): {Size: 5, Mode: 0600, ModTime: time.Unix(500, 0), Uid: 3},
+}
+
+func nodePaths(dir *Directory, nodes []Node) []string {
+	var paths []string
+	for _, node := range nodes {
+		paths = append(paths, dir.relativePath(node.FullPath()))
+	}
+	return paths
+}
+
+func TestDirectory_Find(t *testing.T) {
+	dir := metadataTree(filepath.Join(osRoot(), "tmp"), queryMetadata)
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+	}{
+		{"All", NewQuery(nil), []string{
+			"main.go", "pkg", filepath.Join("pkg", "a"), filepath.Join("pkg", "a", "deep.go"),
+			filepath.Join("pkg", "util.go"), "script.sh", "vendor", filepath.Join("vendor", "lib.go"),
+		}},
+		{"NameRegex", NewQuery(NameMatches(regexp.MustCompile("^(main|lib)"))), []string{
+			"main.go", filepath.Join("vendor", "lib.go"),
+		}},
+		{"ExtensionAndNotVendor", NewQuery(Extension(".go")).Prune(NameMatches(regexp.MustCompile("^vendor$"))), []string{
+			"main.go", filepath.Join("pkg", "a", "deep.go"), filepath.Join("pkg", "util.go"),
+		}},
+		{"Directories", NewQuery(OfType(DirectoryNode)), []string{"pkg", filepath.Join("pkg", "a"), "vendor"}},
+		{"Size", NewQuery(SizeBetween(50, 100)), []string{"main.go", filepath.Join("vendor", "lib.go")}},
+		{"Modified", NewQuery(ModifiedBetween(time.Unix(200, 0), time.Unix(300, 0))), []string{
+			filepath.Join("pkg", "util.go"), "script.sh",
+		}},
+		{"Depth", NewQuery(And(DepthBetween(2, 3), OfType(FileNode))), []string{
+			filepath.Join("pkg", "a", "deep.go"), filepath.Join("pkg", "util.go"), filepath.Join("vendor", "lib.go"),
+		}},
+		{"Permissions", NewQuery(HasPermissions(0111)), []string{"script.sh"}},
+		{"OwnerOr", NewQuery(Or(OwnedBy(2), OwnedBy(3))), []string{filepath.Join("pkg", "a", "deep.go"), "script.sh"}},
+		{"Not", NewQuery(Not(Or(OfType(DirectoryNode), OwnedBy(1)))), []string{
+			filepath.Join("pkg", "a", "deep.go"), "script.sh",
+		}},
+		{"Limit", NewQuery(OfType(FileNode)).Limit(2), []string{"main.go", filepath.Join("pkg", "a", "deep.go")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := nodePaths(dir, dir.Find(tt.query)); !reflect.DeepEqual(actual, tt.expected) {
+				t.Fatalf("matches were incorrect. expected: %v actual: %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDirectory_Find_WithoutMetadataNeverMatchesMetadataPredicates(t *testing.T) {
+	dir := NewDirectory("root", filepath.Join(osRoot(), "tmp"))
+	if _, err := dir.AddFile(filepath.Join(osRoot(), "tmp", "root", "file")); err != nil {
+		t.Fatal(err)
+	}
+	if matches := dir.Find(NewQuery(SizeBetween(0, 1<<62))); len(matches) != 0 {
+		t.Fatalf("no matches were expected but found %d", len(matches))
+	}
+}
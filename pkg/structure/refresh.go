@@ -0,0 +1,206 @@
+package structure
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// Refresh brings a tree that was scanned from disk up to date. Only Directories whose
+// modification time differs from their Metadata are read again; unchanged Directories
+// are merely descended into. options should match the options the tree was scanned
+// with, except that at least BasicMetadata is always captured since the modification
+// times are needed by the next Refresh. Directories without Metadata and Incomplete Directories are always read.
+// Nodes that did not change are kept, so pointers into the tree remain valid, and the
+// digests of every Directory that changed, and of every File in a Directory that was read
+// again whose size, mode or modification time changed, are cleared.
+// The tree is read from its FullPath, so it must not have been scanned as relative.
+// It returns every entry that was added, removed, retargeted or changed type,
+// relative to the current Directory, and an error if one occurs.
+func (dir *Directory) Refresh(options ScanOptions) ([]Change, error) {
+	return dir.RefreshContext(context.Background(), options)
+}
+
+// RefreshContext works like Refresh but stops once ctx is done. It then returns the
+// changes applied so far along with ctx.Err().
+func (dir *Directory) RefreshContext(ctx context.Context, options ScanOptions) ([]Change, error) {
+	if options.Metadata == NoMetadata {
+		options.Metadata = BasicMetadata
+	}
+	diskPath := dir.FullPath()
+	info, err := os.Stat(diskPath)
+	if err != nil {
+		return nil, readError("refresh", diskPath, err)
+	}
+	s, task := newScan(ctx, options, dir, diskPath, info)
+	var changes []Change
+	_, err = s.refresh(task, info, &changes)
+	if err == nil {
+		err = s.failures.err()
+	}
+	sortChanges(changes)
+	return changes, err
+}
+
+// refresh brings the Directory of task up to date with the directory on disk that
+// info describes. It returns true if anything in the Directory or below it changed.
+func (s scanner) refresh(task scanTask, info os.FileInfo, changes *[]Change) (bool, error) {
+	if err := s.ctx.Err(); err != nil {
+		return false, err
+	}
+	dir := task.dir
+	changed := false
+	var subTasks []scanTask
+	if dir.metadata != nil && dir.metadata.ModTime.Equal(info.ModTime()) && !dir.incomplete {
+		if s.options.GitIgnore {
+			task.ignore = task.ignore.withIgnoreFile(task.diskPath, s.repoPath(task.relPath))
+		}
+		for name, subDir := range dir.subDirectories {
+			subPath := filepath.Join(task.diskPath, name)
+			subInfo, err := os.Stat(subPath)
+			if err != nil {
+				if err := s.handleError(dir, subPath, err); err != nil {
+					return false, err
+				}
+				continue
+			}
+			if s.descend(task.depth+1, subInfo) {
+				subTasks = append(subTasks, task.subTask(subDir, subPath, filepath.Join(task.relPath, name), subInfo))
+			}
+		}
+	} else {
+		var err error
+		if subTasks, changed, err = s.reread(task, changes); err != nil {
+			return changed, err
+		}
+	}
+	dir.metadata = s.metadata(info)
+	for _, subTask := range subTasks {
+		subInfo := subTask.ancestors[len(subTask.ancestors)-1]
+		subChanged, err := s.refresh(subTask, subInfo, changes)
+		changed = changed || subChanged
+		if err != nil {
+			return changed, err
+		}
+	}
+	if changed {
+		dir.digest = nil
+	}
+	return changed, nil
+}
+
+// reread reads the Directory of task from disk again and reconciles its entries with
+// what it finds. New subdirectories are scanned completely. It returns the scanTasks
+// of the subdirectories that were kept and true if anything changed.
+func (s scanner) reread(task scanTask, changes *[]Change) ([]scanTask, bool, error) {
+	dir := task.dir
+	fresh := NewDirectory(dir.name, dir.path)
+	freshTask := task
+	freshTask.dir = fresh
+	freshSubTasks, err := s.readDirectory(freshTask)
+	if err != nil {
+		return nil, false, err
+	}
+	if fresh.incomplete && len(fresh.children()) == 0 {
+		// the directory could not be read, so what is known about it is kept
+		dir.incomplete = true
+		return nil, false, nil
+	}
+	dir.incomplete = fresh.incomplete
+	previous := dir.children()
+	changed := dir.reconcile(fresh, task.relPath, changes)
+	var subTasks []scanTask
+	for _, subTask := range freshSubTasks {
+		if existing := dir.subDirectories[subTask.dir.name]; existing != subTask.dir {
+			subTask.dir = existing
+			subTasks = append(subTasks, subTask)
+			continue
+		}
+		changed = true
+		if err := s.scanDirectory(subTask); err != nil {
+			return subTasks, changed, err
+		}
+		if _, ok := previous[subTask.dir.name]; !ok {
+			*changes = append(*changes, Change{Type: Added, Path: subTask.relPath, After: subTask.dir})
+			descendantChanges(subTask.dir, subTask.relPath, Added, changes)
+		}
+	}
+	if changed {
+		dir.digest = nil
+	}
+	return subTasks, changed, nil
+}
+
+// reconcile replaces the entries of dir with those of fresh, which was just read from disk.
+// Entries that exist in both keep their node from dir. Files and Symlinks take the Metadata
+// of fresh, Directories keep theirs until they are refreshed themselves. Files whose size,
+// mode or modification time changed lose their digest, which counts as a change.
+// New Directories are taken from fresh and left for the caller to scan. Those that
+// replace an entry of another type are reported as TypeChanged, the rest are left
+// for the caller to report.
+// It reports every other change at paths below relPath and returns true if there were any.
+func (dir *Directory) reconcile(fresh *Directory, relPath string, changes *[]Change) bool {
+	oldChildren, freshChildren := dir.children(), fresh.children()
+	changed := false
+	for name, oldNode := range oldChildren {
+		path := filepath.Join(relPath, name)
+		freshNode, ok := freshChildren[name]
+		switch {
+		case !ok:
+			*changes = append(*changes, Change{Type: Removed, Path: path, Before: oldNode})
+			if subDir, ok := oldNode.(*Directory); ok {
+				descendantChanges(subDir, path, Removed, changes)
+			}
+		case oldNode.Type() != freshNode.Type():
+			*changes = append(*changes, Change{Type: TypeChanged, Path: path, Before: oldNode, After: freshNode})
+		default:
+			continue
+		}
+		changed = true
+	}
+	for name, freshNode := range freshChildren {
+		if _, ok := oldChildren[name]; !ok && freshNode.Type() != DirectoryNode {
+			*changes = append(*changes, Change{Type: Added, Path: filepath.Join(relPath, name), After: freshNode})
+			changed = true
+		}
+	}
+
+	for name, freshFile := range fresh.files {
+		if oldFile, ok := dir.files[name]; ok {
+			if contentMayDiffer(oldFile.metadata, freshFile.metadata) && oldFile.digest != nil {
+				oldFile.digest = nil
+				changed = true
+			}
+			oldFile.metadata = freshFile.metadata
+			fresh.files[name] = oldFile
+		}
+	}
+	for name, freshLink := range fresh.symlinks {
+		if oldLink, ok := dir.symlinks[name]; ok {
+			if oldLink.target != freshLink.target {
+				*changes = append(*changes, Change{Type: Modified, Path: filepath.Join(relPath, name), Before: oldLink, After: freshLink})
+				changed = true
+				continue
+			}
+			oldLink.metadata = freshLink.metadata
+			fresh.symlinks[name] = oldLink
+		}
+	}
+	for name, freshDir := range fresh.subDirectories {
+		if oldDir, ok := dir.subDirectories[name]; ok {
+			oldDir.linkTarget = freshDir.linkTarget
+			fresh.subDirectories[name] = oldDir
+		}
+	}
+	dir.files, dir.symlinks, dir.subDirectories = fresh.files, fresh.symlinks, fresh.subDirectories
+	return changed
+}
+
+// contentMayDiffer returns true unless before and after describe a File with the same
+// size, mode and modification time
+func contentMayDiffer(before, after *Metadata) bool {
+	if before == nil || after == nil {
+		return true
+	}
+	return before.Size != after.Size || before.Mode != after.Mode || !before.ModTime.Equal(after.ModTime)
+}
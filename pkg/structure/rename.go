@@ -0,0 +1,182 @@
+package structure
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func detectRenames(changes []Change, options DiffOptions) []Change {
+	threshold := options.SimilarityThreshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = 1
+	}
+	sortChanges(changes)
+	consumed := make([]bool, len(changes))
+	var renames []Change
+
+	for i, removed := range changes {
+		if consumed[i] || removed.Type != Removed || removed.Before.Type() != DirectoryNode {
+			continue
+		}
+		best, bestSimilarity := -1, 0.0
+		for j, added := range changes {
+			if consumed[j] || added.Type != Added || added.After.Type() != DirectoryNode {
+				continue
+			}
+			similarity := directorySimilarity(removed.Before.(*Directory), added.After.(*Directory), options.SameFilesystem)
+			if similarity < threshold {
+				continue
+			}
+			if best < 0 || similarity > bestSimilarity ||
+				similarity == bestSimilarity && preferByName(removed.Path, added.Path, changes[best].Path) {
+				best, bestSimilarity = j, similarity
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		added := changes[best]
+		consumeUnder(changes, consumed, Removed, removed.Path)
+		consumeUnder(changes, consumed, Added, added.Path)
+		renames = append(renames, renameChange(removed, added, bestSimilarity))
+		if bestSimilarity < 1 {
+			diffDirectories(removed.Before.(*Directory), added.After.(*Directory), added.Path, &renames)
+		}
+	}
+
+	candidates := map[string][]int{}
+	for j, added := range changes {
+		if consumed[j] || added.Type != Added || added.After.Type() != FileNode {
+			continue
+		}
+		for _, key := range fileKeys(added.After.(*File), options.SameFilesystem) {
+			candidates[key] = append(candidates[key], j)
+		}
+	}
+	for i, removed := range changes {
+		if consumed[i] || removed.Type != Removed || removed.Before.Type() != FileNode {
+			continue
+		}
+		best := -1
+		for _, key := range fileKeys(removed.Before.(*File), options.SameFilesystem) {
+			for _, j := range candidates[key] {
+				if consumed[j] {
+					continue
+				}
+				if best < 0 || preferByName(removed.Path, changes[j].Path, changes[best].Path) {
+					best = j
+				}
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		consumed[i], consumed[best] = true, true
+		renames = append(renames, renameChange(removed, changes[best], 1))
+	}
+
+	result := renames
+	for i, change := range changes {
+		if !consumed[i] {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+func renameChange(removed, added Change, similarity float64) Change {
+	changeType := Moved
+	if filepath.Dir(removed.Path) == filepath.Dir(added.Path) {
+		changeType = Renamed
+	}
+	return Change{
+		Type:       changeType,
+		Path:       added.Path,
+		OldPath:    removed.Path,
+		Similarity: similarity,
+		Before:     removed.Before,
+		After:      added.After,
+	}
+}
+
+// preferByName determines if candidate is a better match for oldPath than current
+// when both are equally similar. Candidates keeping the same name win, after
+// that the first path in sorted order wins.
+func preferByName(oldPath string, candidate string, current string) bool {
+	name := filepath.Base(oldPath)
+	candidateSameName := filepath.Base(candidate) == name
+	currentSameName := filepath.Base(current) == name
+	if candidateSameName != currentSameName {
+		return candidateSameName
+	}
+	return candidate < current
+}
+
+func consumeUnder(changes []Change, consumed []bool, changeType ChangeType, path string) {
+	prefix := path + string(os.PathSeparator)
+	for i, change := range changes {
+		if change.Type == changeType && (change.Path == path || strings.HasPrefix(change.Path, prefix)) {
+			consumed[i] = true
+		}
+	}
+}
+
+// fileKeys returns the keys by which a File can be paired with another File.
+// Empty Files are only paired by inode since all of them share a digest.
+func fileKeys(file *File, sameFilesystem bool) []string {
+	var keys []string
+	if file.digest != nil && (file.metadata == nil || file.metadata.Size > 0) {
+		keys = append(keys, "digest:"+string(file.digest))
+	}
+	if sameFilesystem && file.metadata != nil {
+		keys = append(keys, fmt.Sprintf("inode:%d:%d", file.metadata.Device, file.metadata.Inode))
+	}
+	return keys
+}
+
+// directorySimilarity returns the fraction of Files that are found at the same
+// relative path with the same contents in both Directories
+func directorySimilarity(before, after *Directory, sameFilesystem bool) float64 {
+	beforeFiles := relativeFileKeys(before, "", sameFilesystem, map[string][]string{})
+	afterFiles := relativeFileKeys(after, "", sameFilesystem, map[string][]string{})
+	total := len(beforeFiles)
+	if len(afterFiles) > total {
+		total = len(afterFiles)
+	}
+	if len(beforeFiles) == 0 || len(afterFiles) == 0 {
+		return 0
+	}
+	if before.ContentEquals(after) {
+		return 1
+	}
+	common := 0
+	for path, keys := range beforeFiles {
+		if sharesKey(keys, afterFiles[path]) {
+			common++
+		}
+	}
+	return float64(common) / float64(total)
+}
+
+func relativeFileKeys(dir *Directory, relPath string, sameFilesystem bool, keys map[string][]string) map[string][]string {
+	for name, file := range dir.files {
+		keys[filepath.Join(relPath, name)] = fileKeys(file, sameFilesystem)
+	}
+	for name, subDir := range dir.subDirectories {
+		relativeFileKeys(subDir, filepath.Join(relPath, name), sameFilesystem, keys)
+	}
+	return keys
+}
+
+func sharesKey(keys []string, others []string) bool {
+	for _, key := range keys {
+		for _, other := range others {
+			if key == other {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package structure
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffWithOptions_DetectsFileMove(t *testing.T) {
+	before := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{filepath.Join("a", "x.go"): "x", filepath.Join("b", "y.go"): "y"})
+	after := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{filepath.Join("b", "x.go"): "x", filepath.Join("b", "y.go"): "y"})
+	changes := DiffWithOptions(before, after, DiffOptions{DetectRenames: true})
+	expected := []string{
+		"removed: a",
+		"moved: " + filepath.Join("a", "x.go") + " -> " + filepath.Join("b", "x.go"),
+	}
+	if actual := changeStrings(changes); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+}
+
+func TestDiffWithOptions_DetectsFileRename(t *testing.T) {
+	before := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{"old.go": "x"})
+	after := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{"new.go": "x"})
+	changes := DiffWithOptions(before, after, DiffOptions{DetectRenames: true})
+	if actual, expected := changeStrings(changes), []string{"renamed: old.go -> new.go"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+}
+
+func TestDiffWithOptions_DoesNotPairDifferentContents(t *testing.T) {
+	before := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{"old.go": "x"})
+	after := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{"new.go": "y"})
+	changes := DiffWithOptions(before, after, DiffOptions{DetectRenames: true})
+	if actual, expected := changeStrings(changes), []string{"added: new.go", "removed: old.go"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+}
+
+func TestDiffWithOptions_DetectsDirectoryMove(t *testing.T) {
+	before := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{
+		filepath.Join("src", "lib", "a.go"): "a",
+		filepath.Join("src", "lib", "b.go"): "b",
+		"other.go":                          "o",
+	})
+	after := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{
+		filepath.Join("pkg", "lib", "a.go"): "a",
+		filepath.Join("pkg", "lib", "b.go"): "b",
+		"other.go":                          "o",
+	})
+	changes := DiffWithOptions(before, after, DiffOptions{DetectRenames: true})
+	expected := []string{"renamed: src -> pkg"}
+	if actual := changeStrings(changes); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+	if changes[0].Similarity != 1 {
+		t.Fatalf("similarity was incorrect. expected: 1 actual: %f", changes[0].Similarity)
+	}
+}
+
+func TestDiffWithOptions_SimilarityThreshold(t *testing.T) {
+	before := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{
+		filepath.Join("old", "a.go"): "a",
+		filepath.Join("old", "b.go"): "b",
+		filepath.Join("old", "c.go"): "c",
+		filepath.Join("old", "d.go"): "d",
+	})
+	after := digestTree(filepath.Join(osRoot(), "tmp"), map[string]string{
+		filepath.Join("new", "a.go"): "a",
+		filepath.Join("new", "b.go"): "b",
+		filepath.Join("new", "c.go"): "c",
+		filepath.Join("new", "d.go"): "changed",
+	})
+
+	exact := DiffWithOptions(before, after, DiffOptions{DetectRenames: true})
+	for _, change := range exact {
+		if change.Type == Renamed && change.Path == "new" {
+			t.Fatal("directories were paired although they were not identical")
+		}
+	}
+
+	similar := DiffWithOptions(before, after, DiffOptions{DetectRenames: true, SimilarityThreshold: 0.75})
+	expected := []string{
+		"renamed: old -> new",
+		"modified: " + filepath.Join("new", "d.go"),
+	}
+	if actual := changeStrings(similar); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+	if similar[0].Similarity != 0.75 {
+		t.Fatalf("similarity was incorrect. expected: 0.75 actual: %f", similar[0].Similarity)
+	}
+}
+
+func TestDiffWithOptions_PairsByInodeOnSameFilesystem(t *testing.T) {
+	before := NewDirectory("root", filepath.Join(osRoot(), "tmp"))
+	after := NewDirectory("root", filepath.Join(osRoot(), "tmp"))
+	oldFile, err := before.AddFile(filepath.Join(osRoot(), "tmp", "root", "old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFile, err := after.AddFile(filepath.Join(osRoot(), "tmp", "root", "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldFile.SetMetadata(&Metadata{Size: 1, Inode: 42, Device: 1})
+	newFile.SetMetadata(&Metadata{Size: 1, Inode: 42, Device: 1})
+
+	if changes := DiffWithOptions(before, after, DiffOptions{DetectRenames: true}); len(changes) != 2 {
+		t.Fatalf("files should not have been paired without SameFilesystem: %v", changeStrings(changes))
+	}
+	changes := DiffWithOptions(before, after, DiffOptions{DetectRenames: true, SameFilesystem: true})
+	if actual, expected := changeStrings(changes), []string{"renamed: old -> new"}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+}
@@ -0,0 +1,236 @@
+package structure
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Renderer writes a textual representation of a Directory tree to an io.Writer
+type Renderer interface {
+	Render(w io.Writer, dir *Directory) error
+}
+
+// RenderStyle selects how NewRenderer draws a tree
+type RenderStyle int
+
+const (
+	// TreeStyle draws the tree with box drawing characters like tree(1)
+	TreeStyle RenderStyle = iota
+	// ASCIITreeStyle draws the tree like TreeStyle using only ASCII characters
+	ASCIITreeStyle
+	// IndentStyle indents each entry by four spaces per level and marks
+	// Directories with a trailing '/'. Names and targets are quoted like FormatTree
+	// does and sizes and counts are left out, so ParseTree reads the output back.
+	IndentStyle
+	// PathStyle writes the full path of every entry
+	PathStyle
+)
+
+// RenderOptions controls what a Renderer writes
+type RenderOptions struct {
+	// DirectoriesFirst lists the Directories of each Directory before its other entries
+	DirectoriesFirst bool
+	// Natural sorts names with embedded numbers by value, so "file2" comes before "file10"
+	Natural bool
+	// MaxDepth limits the levels below the root that are written. Zero means no limit.
+	MaxDepth int
+	// ShowSizes writes the size of each File from its Metadata and the total size of
+	// the Files below each Directory
+	ShowSizes bool
+	// ShowCounts writes the number of Directories, Files and Symlinks directly inside
+	// each Directory
+	ShowCounts bool
+}
+
+// NewRenderer creates a Renderer that draws trees in style. Entries are sorted by name
+// within each Directory and Symlinks are written as "name -> target".
+func NewRenderer(style RenderStyle, options RenderOptions) Renderer {
+	return renderer{style: style, options: options}
+}
+
+type renderer struct {
+	style   RenderStyle
+	options RenderOptions
+}
+
+// treeGlyphs are the connectors and prefixes a tree is drawn with
+type treeGlyphs struct {
+	branch, last, pipe, space string
+}
+
+var (
+	boxGlyphs   = treeGlyphs{branch: "├── ", last: "└── ", pipe: "│   ", space: "    "}
+	asciiGlyphs = treeGlyphs{branch: "|-- ", last: "`-- ", pipe: "|   ", space: "    "}
+)
+
+// Render writes dir and its descendants to w, one entry per line
+func (r renderer) Render(w io.Writer, dir *Directory) error {
+	root := dir.FullPath()
+	if r.style == IndentStyle {
+		// ParseTree only uses the last element of the root, so only the name is
+		// written where the path would not be read back
+		if dir.name == "" || specName(dir.name) != dir.name || strings.Contains(root, "->") || strings.ContainsAny(root, "\n\r") {
+			root = specName(dir.name)
+		}
+		root += "/"
+	}
+	if _, err := fmt.Fprintln(w, root+r.details(dir)); err != nil {
+		return err
+	}
+	return r.renderChildren(w, dir, "", 1)
+}
+
+func (r renderer) renderChildren(w io.Writer, dir *Directory, prefix string, depth int) error {
+	if r.options.MaxDepth > 0 && depth > r.options.MaxDepth {
+		return nil
+	}
+	children := r.sortedChildren(dir)
+	glyphs := boxGlyphs
+	if r.style == ASCIITreeStyle {
+		glyphs = asciiGlyphs
+	}
+	for i, child := range children {
+		var line, childPrefix string
+		switch r.style {
+		case TreeStyle, ASCIITreeStyle:
+			connector, next := glyphs.branch, glyphs.pipe
+			if i == len(children)-1 {
+				connector, next = glyphs.last, glyphs.space
+			}
+			line, childPrefix = prefix+connector+child.Name(), prefix+next
+		case IndentStyle:
+			line, childPrefix = strings.Repeat("    ", depth)+specName(child.Name()), ""
+			if child.Type() == DirectoryNode {
+				line += "/"
+			}
+		default:
+			line = child.FullPath()
+		}
+		if link, ok := child.(*Symlink); ok {
+			if r.style == IndentStyle {
+				line += " -> " + specTarget(link.target)
+			} else {
+				line += " -> " + link.target
+			}
+		}
+		if _, err := fmt.Fprintln(w, line+r.details(child)); err != nil {
+			return err
+		}
+		if subDir, ok := child.(*Directory); ok {
+			if err := r.renderChildren(w, subDir, childPrefix, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// details returns the sizes and counts to write after node. IndentStyle has none,
+// since ParseTree would read them as part of the name.
+func (r renderer) details(node Node) string {
+	if r.style == IndentStyle {
+		return ""
+	}
+	var details []string
+	switch node := node.(type) {
+	case *Directory:
+		if r.options.ShowCounts {
+			details = append(details, fmt.Sprintf("%d directories, %d files, %d symlinks",
+				len(node.subDirectories), len(node.files), len(node.symlinks)))
+		}
+		if r.options.ShowSizes {
+			details = append(details, formatSize(totalSize(node)))
+		}
+	case *File:
+		if r.options.ShowSizes && node.metadata != nil {
+			details = append(details, formatSize(node.metadata.Size))
+		}
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(details, ", ") + "]"
+}
+
+// sortedChildren returns the children of dir in the order the options ask for
+func (r renderer) sortedChildren(dir *Directory) []Node {
+	children := dir.sortedChildren()
+	sort.SliceStable(children, func(i, j int) bool {
+		if r.options.DirectoriesFirst {
+			iDir, jDir := children[i].Type() == DirectoryNode, children[j].Type() == DirectoryNode
+			if iDir != jDir {
+				return iDir
+			}
+		}
+		if r.options.Natural {
+			return naturalLess(children[i].Name(), children[j].Name())
+		}
+		return children[i].Name() < children[j].Name()
+	})
+	return children
+}
+
+// totalSize returns the sum of the sizes in the Metadata of every File below dir
+func totalSize(dir *Directory) int64 {
+	var size int64
+	for _, file := range dir.files {
+		if file.metadata != nil {
+			size += file.metadata.Size
+		}
+	}
+	for _, subDir := range dir.subDirectories {
+		size += totalSize(subDir)
+	}
+	return size
+}
+
+// formatSize formats a number of bytes with a binary unit, such as "1.5 KiB"
+func formatSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	unit := -1
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+// naturalLess compares a and b treating runs of digits as numbers
+func naturalLess(a string, b string) bool {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+		if aDigits == "" || bDigits == "" {
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		aValue, bValue := strings.TrimLeft(aDigits, "0"), strings.TrimLeft(bDigits, "0")
+		if len(aValue) != len(bValue) {
+			return len(aValue) < len(bValue)
+		}
+		if aValue != bValue {
+			return aValue < bValue
+		}
+		if len(aDigits) != len(bDigits) {
+			return len(aDigits) < len(bDigits)
+		}
+		a, b = a[len(aDigits):], b[len(bDigits):]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	return s[:end]
+}
@@ -0,0 +1,119 @@
+package structure
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const renderSpec = `
+dir1/
+    file10
+    file2
+    sub1/
+        file3
+        link -> ../file2
+    a/
+`
+
+func render(t *testing.T, renderer Renderer, dir *Directory) string {
+	var buffer bytes.Buffer
+	if err := renderer.Render(&buffer, dir); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func TestRenderer_Styles(t *testing.T) {
+	root := filepath.Join(osRoot(), "tmp", "dir1")
+	tests := []struct {
+		name     string
+		style    RenderStyle
+		expected []string
+	}{
+		{"Tree", TreeStyle, []string{root, "├── a", "├── file10", "├── file2", "└── sub1", "    ├── file3", "    └── link -> ../file2"}},
+		{"ASCIITree", ASCIITreeStyle, []string{root, "|-- a", "|-- file10", "|-- file2", "`-- sub1", "    |-- file3", "    `-- link -> ../file2"}},
+		{"Indent", IndentStyle, []string{root + "/", "    a/", "    file10", "    file2", "    sub1/", "        file3", "        link -> ../file2"}},
+		{"Path", PathStyle, []string{
+			root,
+			filepath.Join(root, "a"),
+			filepath.Join(root, "file10"),
+			filepath.Join(root, "file2"),
+			filepath.Join(root, "sub1"),
+			filepath.Join(root, "sub1", "file3"),
+			filepath.Join(root, "sub1", "link") + " -> ../file2",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := strings.Join(tt.expected, "\n") + "\n"
+			dir := fixtureTree(filepath.Join(osRoot(), "tmp"), renderSpec)
+			if actual := render(t, NewRenderer(tt.style, RenderOptions{}), dir); actual != expected {
+				t.Fatalf("rendered tree was incorrect. expected:\n%s\nactual:\n%s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestRenderer_Options(t *testing.T) {
+	options := RenderOptions{DirectoriesFirst: true, Natural: true, MaxDepth: 1, ShowSizes: true, ShowCounts: true}
+	expected := strings.Join([]string{
+		filepath.Join(osRoot(), "tmp", "dir1") + " [2 directories, 2 files, 0 symlinks, 2.1 KiB]",
+		"├── a [0 directories, 0 files, 0 symlinks, 0 B]",
+		"├── sub1 [0 directories, 1 files, 1 symlinks, 1 B]",
+		"├── file2 [100 B]",
+		"└── file10 [2.0 KiB]",
+	}, "\n") + "\n"
+	dir := fixtureTree(filepath.Join(osRoot(), "tmp"), renderSpec)
+	dir.File("file10").SetMetadata(&Metadata{Size: 2048})
+	dir.File("file2").SetMetadata(&Metadata{Size: 100})
+	dir.SubDirectory("sub1").File("file3").SetMetadata(&Metadata{Size: 1})
+	if actual := render(t, NewRenderer(TreeStyle, options), dir); actual != expected {
+		t.Fatalf("rendered tree was incorrect. expected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
+
+func TestRenderer_IndentStyleParsesBack(t *testing.T) {
+	expected := fixtureTree(filepath.Join(osRoot(), "tmp"), renderSpec)
+	actual, err := ParseTree(render(t, NewRenderer(IndentStyle, RenderOptions{}), expected), expected.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.StructureEquals(expected) {
+		t.Fatal("rendered tree did not parse back into the same tree")
+	}
+}
+
+func TestRenderer_IndentStyleParsesBackUnusualNames(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  *Directory
+	}{
+		{"Names", fixtureTree(filepath.Join(osRoot(), "tmp"), "dir1/\n    \"x -> y\"\n    \"  lead\"/\n        \"trail \"\n    link -> \"target \"\n")},
+		{"Root", fixtureTree(osRoot(), "\"\"/\n    file1\n")},
+		{"RootNeedsQuotes", fixtureTree(filepath.Join(osRoot(), "tmp"), "\"a -> b\"/\n    file1\n")},
+	}
+	options := RenderOptions{ShowSizes: true, ShowCounts: true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered := render(t, NewRenderer(IndentStyle, options), tt.dir)
+			actual, err := ParseTree(rendered, tt.dir.Path())
+			if err != nil {
+				t.Fatalf("%v\n%s", err, rendered)
+			}
+			if actual.Name() != tt.dir.Name() || !actual.StructureEquals(tt.dir) {
+				t.Fatalf("rendered tree did not parse back into the same tree:\n%s", rendered)
+			}
+		})
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	ordered := []string{"a", "a1", "a01", "a2", "a10", "b", "file2.txt", "file10.txt"}
+	for i := 0; i < len(ordered)-1; i++ {
+		if !naturalLess(ordered[i], ordered[i+1]) || naturalLess(ordered[i+1], ordered[i]) {
+			t.Fatalf("expected %s to sort before %s", ordered[i], ordered[i+1])
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package structure
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Filter decides whether an entry found during a scan is kept. path is the
+// path of the entry relative to the root of the scan and info describes it.
+type Filter func(path string, info os.FileInfo) bool
+
+// HiddenPolicy controls how entries whose names begin with a dot are scanned
+type HiddenPolicy int
+
+const (
+	// IncludeHidden scans hidden entries like any other entry
+	IncludeHidden HiddenPolicy = iota
+	// ExcludeHidden leaves hidden Files, Symlinks and Directories out of the tree
+	ExcludeHidden
+)
+
+// MetadataLevel controls how much Metadata is captured during a scan
+type MetadataLevel int
+
+const (
+	// NoMetadata captures no Metadata
+	NoMetadata MetadataLevel = iota
+	// BasicMetadata captures size, mode and modification time
+	BasicMetadata
+	// FullMetadata also captures owner, inode and device
+	FullMetadata
+)
+
+// ErrorPolicy controls what happens when an entry cannot be read during a scan
+type ErrorPolicy int
+
+const (
+	// AbortOnError stops the scan and returns the first error
+	AbortOnError ErrorPolicy = iota
+	// SkipOnError leaves entries that cannot be read out of the tree and continues
+	SkipOnError
+	// CollectErrors continues like SkipOnError but marks the Directories that could not be
+	// read completely as Incomplete. The scan then returns the tree along with a *ScanError
+	// that lists every path that could not be read.
+	CollectErrors
+)
+
+// ScanOptions controls how GetDirectoryStructureWithOptions builds a Directory tree.
+// The zero value scans everything without Metadata, like GetDirectoryStructure.
+type ScanOptions struct {
+	// Relative roots the tree at the root of the filesystem instead of at the parent of fullPath
+	Relative bool
+	// MaxDepth is the number of levels below the root that are scanned. Zero means no limit.
+	MaxDepth int
+	// IncludeFile keeps only the Files and Symlinks it returns true for
+	IncludeFile Filter
+	// ExcludeFile leaves out the Files and Symlinks it returns true for
+	ExcludeFile Filter
+	// IncludeDirectory keeps only the Directories it returns true for.
+	// Directories that are not kept are not descended into.
+	IncludeDirectory Filter
+	// ExcludeDirectory leaves out the Directories it returns true for
+	// Directories that are left out are not descended into.
+	ExcludeDirectory Filter
+	// Hidden controls how entries whose names begin with a dot are scanned
+	Hidden HiddenPolicy
+	// FollowSymlinks scans the directories symlinks point to in their place.
+	// A symlink that points to one of its own ancestors is not followed.
+	FollowSymlinks bool
+	// OneFilesystem does not descend into Directories on a different device than the root
+	OneFilesystem bool
+	// Metadata controls how much Metadata is captured for every entry
+	Metadata MetadataLevel
+	// Errors controls what happens when an entry cannot be read
+	Errors ErrorPolicy
+	// Workers is the number of directories read from disk concurrently. Values below
+	// two scan serially. The resulting tree is the same either way, but Filters must be
+	// safe for concurrent use when more than one worker is used.
+	Workers int
+	// GitIgnore leaves out every entry that git would ignore. The .gitignore files of
+	// every directory are honoured, along with those of parent directories and
+	// .git/info/exclude when the root is inside a repository. Ignored Directories and
+	// .git itself are not descended into.
+	GitIgnore bool
+}
+
+type scanner struct {
+	ctx        context.Context
+	options    ScanOptions
+	rootDevice uint64
+	// repoPrefix is the slash separated path of the root of the scan relative to
+	// the root of its git repository
+	repoPrefix string
+	// failures collects the paths that could not be read when errors are collected
+	failures *scanFailures
+}
+
+// scanFailures collects the paths that could not be read during a scan. It is shared
+// by every copy of a scanner, so it is safe for concurrent use.
+type scanFailures struct {
+	lock     sync.Mutex
+	failures []*PathError
+}
+
+func (failures *scanFailures) add(failure *PathError) {
+	failures.lock.Lock()
+	defer failures.lock.Unlock()
+	failures.failures = append(failures.failures, failure)
+}
+
+// err returns a *ScanError listing the failures sorted by path or nil if there are none
+func (failures *scanFailures) err() error {
+	failures.lock.Lock()
+	defer failures.lock.Unlock()
+	if len(failures.failures) == 0 {
+		return nil
+	}
+	sorted := append([]*PathError(nil), failures.failures...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	return &ScanError{Failures: sorted}
+}
+
+// scanTask describes a directory on disk that is to be scanned into dir
+type scanTask struct {
+	dir      *Directory
+	diskPath string
+	// relPath is the path of dir relative to the root of the scan
+	relPath string
+	// depth is the number of levels dir is below the root of the scan
+	depth int
+	// ancestors holds the directories on disk from the root of the scan down to
+	// diskPath and is used to detect symlink loops
+	ancestors []os.FileInfo
+	// ignore holds the gitignore rules that apply inside dir
+	ignore ignoreRules
+}
+
+// newScan creates a scanner and the scanTask for the root of a scan of the
+// directory at diskPath, which info describes, into root
+func newScan(ctx context.Context, options ScanOptions, root *Directory, diskPath string, info os.FileInfo) (scanner, scanTask) {
+	s := scanner{ctx: ctx, options: options, rootDevice: NewMetadata(info).Device, failures: &scanFailures{}}
+	task := scanTask{dir: root, diskPath: diskPath, ancestors: []os.FileInfo{info}}
+	if options.GitIgnore {
+		task.ignore, s.repoPrefix = repositoryIgnoreRules(diskPath)
+	}
+	return s, task
+}
+
+// scanDirectory scans the directory described by task and all of its descendants
+func (s scanner) scanDirectory(task scanTask) error {
+	subTasks, err := s.readDirectory(task)
+	if err != nil {
+		return err
+	}
+	for _, subTask := range subTasks {
+		if err := s.scanDirectory(subTask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDirectory reads the directory described by task from disk and adds its entries
+// to task.dir. It returns a scanTask for every subdirectory that should be scanned next.
+func (s scanner) readDirectory(task scanTask) ([]scanTask, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(task.diskPath)
+	if err != nil {
+		return nil, s.handleError(task.dir, task.diskPath, err)
+	}
+	if s.options.GitIgnore {
+		task.ignore = task.ignore.withIgnoreFile(task.diskPath, s.repoPath(task.relPath))
+	}
+	var subTasks []scanTask
+	for _, info := range infos {
+		entryPath := filepath.Join(task.diskPath, info.Name())
+		entryRelPath := filepath.Join(task.relPath, info.Name())
+		if s.skip(task, entryRelPath, info) {
+			continue
+		}
+		var subTask *scanTask
+		var err error
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			subTask, err = s.readSymlink(task, entryPath, entryRelPath, info)
+		case info.IsDir():
+			subTask = s.addSubDirectory(task, entryPath, entryRelPath, info, "")
+		case keep(s.options.IncludeFile, s.options.ExcludeFile, entryRelPath, info):
+			task.dir.addChildFile(info.Name(), s.metadata(info))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if subTask != nil {
+			subTasks = append(subTasks, *subTask)
+		}
+	}
+	return subTasks, nil
+}
+
+// addSubDirectory adds the directory at diskPath to the directory of parent. It returns
+// a scanTask for the new Directory or nil if the options say it should not be scanned.
+// linkTarget is set if the directory was reached through a symlink.
+func (s scanner) addSubDirectory(parent scanTask, diskPath string, relPath string, info os.FileInfo, linkTarget string) *scanTask {
+	if !keep(s.options.IncludeDirectory, s.options.ExcludeDirectory, relPath, info) {
+		return nil
+	}
+	subDir := parent.dir.addChildDirectory(filepath.Base(relPath), s.metadata(info))
+	subDir.linkTarget = linkTarget
+	if !s.descend(parent.depth+1, info) {
+		return nil
+	}
+	subTask := parent.subTask(subDir, diskPath, relPath, info)
+	return &subTask
+}
+
+// descend determines if a directory at depth that info describes should be scanned
+func (s scanner) descend(depth int, info os.FileInfo) bool {
+	if s.options.MaxDepth > 0 && depth >= s.options.MaxDepth {
+		return false
+	}
+	return !s.options.OneFilesystem || NewMetadata(info).Device == s.rootDevice
+}
+
+// subTask creates the scanTask for subDir, a Directory inside the Directory of task
+func (task scanTask) subTask(subDir *Directory, diskPath string, relPath string, info os.FileInfo) scanTask {
+	return scanTask{
+		dir:       subDir,
+		diskPath:  diskPath,
+		relPath:   relPath,
+		depth:     task.depth + 1,
+		ancestors: append(task.ancestors[:len(task.ancestors):len(task.ancestors)], info),
+		ignore:    task.ignore,
+	}
+}
+
+// readSymlink adds the symlink at diskPath to the directory of parent. If symlinks are
+// followed and it points to a directory that is not one of its own ancestors, the
+// directory is added in its place and a scanTask for it is returned.
+func (s scanner) readSymlink(parent scanTask, diskPath string, relPath string, info os.FileInfo) (*scanTask, error) {
+	target, err := os.Readlink(diskPath)
+	if err != nil {
+		return nil, s.handleError(parent.dir, diskPath, err)
+	}
+	if s.options.FollowSymlinks {
+		if targetInfo, err := os.Stat(diskPath); err == nil && targetInfo.IsDir() && !isAncestor(targetInfo, parent.ancestors) {
+			return s.addSubDirectory(parent, diskPath, relPath, targetInfo, target), nil
+		}
+	}
+	if keep(s.options.IncludeFile, s.options.ExcludeFile, relPath, info) {
+		parent.dir.addChildSymlink(info.Name(), target, s.metadata(info))
+	}
+	return nil, nil
+}
+
+// skip determines if an entry is left out because it is hidden or ignored by git
+func (s scanner) skip(task scanTask, relPath string, info os.FileInfo) bool {
+	if s.options.Hidden == ExcludeHidden && strings.HasPrefix(info.Name(), ".") {
+		return true
+	}
+	if s.options.GitIgnore {
+		if info.Name() == ".git" {
+			return true
+		}
+		return task.ignore.ignored(s.repoPath(relPath), info.IsDir())
+	}
+	return false
+}
+
+// repoPath converts a path relative to the root of the scan to a slash separated
+// path relative to the root of the git repository
+func (s scanner) repoPath(relPath string) string {
+	return path.Join(s.repoPrefix, filepath.ToSlash(relPath))
+}
+
+// handleError decides what happens when the entry at diskPath in dir cannot be read.
+// It returns the error as a *PathError if the scan is to stop.
+func (s scanner) handleError(dir *Directory, diskPath string, err error) error {
+	switch s.options.Errors {
+	case SkipOnError:
+		return nil
+	case CollectErrors:
+		s.failures.add(readError("scan", diskPath, err))
+		dir.incomplete = true
+		return nil
+	}
+	return readError("scan", diskPath, err)
+}
+
+func (s scanner) metadata(info os.FileInfo) *Metadata {
+	switch s.options.Metadata {
+	case BasicMetadata:
+		return &Metadata{Size: info.Size(), Mode: info.Mode(), ModTime: info.ModTime()}
+	case FullMetadata:
+		return NewMetadata(info)
+	default:
+		return nil
+	}
+}
+
+func keep(include Filter, exclude Filter, path string, info os.FileInfo) bool {
+	return (include == nil || include(path, info)) && (exclude == nil || !exclude(path, info))
+}
+
+// isAncestor determines if info describes the same directory on disk as one of
+// ancestors. On most platforms this compares device and inode.
+func isAncestor(info os.FileInfo, ancestors []os.FileInfo) bool {
+	for _, ancestor := range ancestors {
+		if os.SameFile(info, ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+func (dir *Directory) addChildDirectory(name string, metadata *Metadata) *Directory {
+	if dir.subDirectories == nil {
+		dir.subDirectories = map[string]*Directory{}
+	}
+	subDir := NewDirectory(name, dir.FullPath())
+	subDir.metadata = metadata
+	dir.subDirectories[name] = subDir
+	return subDir
+}
+
+func (dir *Directory) addChildFile(name string, metadata *Metadata) *File {
+	if dir.files == nil {
+		dir.files = map[string]*File{}
+	}
+	file := NewFile(name, dir.FullPath())
+	file.metadata = metadata
+	dir.files[name] = &file
+	return &file
+}
+
+func (dir *Directory) addChildSymlink(name string, target string, metadata *Metadata) *Symlink {
+	if dir.symlinks == nil {
+		dir.symlinks = map[string]*Symlink{}
+	}
+	link := NewSymlink(name, dir.FullPath(), target)
+	link.metadata = metadata
+	dir.symlinks[name] = &link
+	return &link
+}
@@ -0,0 +1,51 @@
+package structure
+
+import "sync"
+
+// scanParallel scans the directory described by root and all of its descendants using
+// workers goroutines. Each Directory is only ever filled by the worker that reads it,
+// so the tree itself needs no locking.
+func (s scanner) scanParallel(root scanTask, workers int) error {
+	var (
+		mu    sync.Mutex
+		ready = sync.NewCond(&mu)
+		queue = []scanTask{root}
+		// pending counts the tasks that are queued or being read
+		pending  = 1
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	worker := func() {
+		defer wg.Done()
+		for {
+			mu.Lock()
+			for len(queue) == 0 && pending > 0 && firstErr == nil {
+				ready.Wait()
+			}
+			if pending == 0 || firstErr != nil {
+				mu.Unlock()
+				return
+			}
+			task := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			mu.Unlock()
+
+			subTasks, err := s.readDirectory(task)
+
+			mu.Lock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			queue = append(queue, subTasks...)
+			pending += len(subTasks) - 1
+			mu.Unlock()
+			ready.Broadcast()
+		}
+	}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go worker()
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,411 @@
+package structure
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// A snapshot starts with a header of the magic bytes "DSNP", a version byte and a
+// flags byte. The rest of the snapshot is gzip compressed if snapshotCompressed is
+// set. It holds the path of the root as a string, followed by the root and its
+// descendants in pre-order. Every node is a record of
+//
+//	kind      one byte, 'd' for a Directory, 'f' for a File or 'l' for a Symlink
+//	name      a string
+//	flags     one byte saying which of the following fields are present
+//	metadata  size, mode, modification time in seconds and nanoseconds, uid, gid,
+//	          inode and device as varints
+//	digest    a string
+//	target    a string, the target of a Symlink or the linkTarget of a Directory
+//
+// The flag nodeIncomplete has no field of its own and marks an Incomplete Directory.
+// It was added in version 2; version 1 snapshots, which lack it, are still read.
+//
+// The records of the children of a Directory follow it directly and are ended by a
+// single 'e' byte, so paths are never stored but follow from the nesting.
+// Strings are stored as a uvarint length followed by their bytes.
+
+const (
+	snapshotMagic   = "DSNP"
+	snapshotVersion = 2
+	// snapshotMinVersion is the oldest version ReadSnapshot still reads
+	snapshotMinVersion = 1
+
+	snapshotCompressed = 1 << 0
+
+	nodeHasMetadata = 1 << 0
+	nodeHasDigest   = 1 << 1
+	nodeHasTarget   = 1 << 2
+	nodeIncomplete  = 1 << 3
+	nodeKnownFlags  = nodeHasMetadata | nodeHasDigest | nodeHasTarget | nodeIncomplete
+	// nodeKnownFlagsV1 are the node flags of version 1 snapshots
+	nodeKnownFlagsV1 = nodeHasMetadata | nodeHasDigest | nodeHasTarget
+
+	recordDirectory = 'd'
+	recordFile      = 'f'
+	recordSymlink   = 'l'
+	recordEnd       = 'e'
+
+	// maxSnapshotString limits the length of strings read from a snapshot so
+	// that a corrupt snapshot cannot cause huge allocations
+	maxSnapshotString = 1 << 16
+	// maxSnapshotDepth limits how deeply Directories in a snapshot may be nested so
+	// that a corrupt snapshot cannot recurse without bound. It is deeper than any
+	// path a filesystem allows.
+	maxSnapshotDepth = 1 << 11
+)
+
+// ErrSnapshotVersion is returned when reading a snapshot written by a newer,
+// incompatible version of this package
+var ErrSnapshotVersion = errors.New("structure: unsupported snapshot version")
+
+// ErrInvalidSnapshot is returned when reading data that is not a valid snapshot
+var ErrInvalidSnapshot = errors.New("structure: invalid snapshot")
+
+// InvalidSnapshotError is returned for a compressed snapshot that cannot be decompressed.
+// It matches ErrInvalidSnapshot with errors.Is and Cause is the error of the decompressor.
+type InvalidSnapshotError struct {
+	Cause error
+}
+
+func (err *InvalidSnapshotError) Error() string {
+	return ErrInvalidSnapshot.Error() + ": " + err.Cause.Error()
+}
+
+// Is reports whether target is ErrInvalidSnapshot
+func (err *InvalidSnapshotError) Is(target error) bool { return target == ErrInvalidSnapshot }
+
+// Unwrap returns the error of the decompressor
+func (err *InvalidSnapshotError) Unwrap() error { return err.Cause }
+
+// SnapshotOptions controls how WriteSnapshot encodes a Directory tree
+type SnapshotOptions struct {
+	// Compress compresses everything after the header with gzip
+	Compress bool
+}
+
+// WriteSnapshot writes dir and all of its descendants to w in a compact binary form,
+// including Metadata and digests where they are present. The tree is written as it is
+// walked, so no second copy of it is held in memory. It can be read back with ReadSnapshot.
+func WriteSnapshot(w io.Writer, dir *Directory, options SnapshotOptions) error {
+	var flags byte
+	if options.Compress {
+		flags |= snapshotCompressed
+	}
+	if _, err := w.Write([]byte{snapshotMagic[0], snapshotMagic[1], snapshotMagic[2], snapshotMagic[3], snapshotVersion, flags}); err != nil {
+		return err
+	}
+	var compressor *gzip.Writer
+	if options.Compress {
+		compressor = gzip.NewWriter(w)
+		w = compressor
+	}
+	writer := snapshotWriter{w: bufio.NewWriter(w)}
+	writer.writeString(dir.path)
+	writer.writeDirectory(dir)
+	if writer.err == nil {
+		writer.err = writer.w.Flush()
+	}
+	if compressor != nil && writer.err == nil {
+		writer.err = compressor.Close()
+	}
+	return writer.err
+}
+
+// ReadSnapshot reads a Directory tree written by WriteSnapshot from r. The tree is
+// built as it is read. It returns ErrSnapshotVersion if the snapshot was written by
+// a newer version of the format and an error matching ErrInvalidSnapshot if r does not
+// hold a snapshot, including one that is truncated or corrupt, has invalid or duplicate
+// names or Directories nested too deeply.
+func ReadSnapshot(r io.Reader) (*Directory, error) {
+	header := make([]byte, len(snapshotMagic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalidSnapshot
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return nil, ErrInvalidSnapshot
+	}
+	version := header[len(snapshotMagic)]
+	if version < snapshotMinVersion || version > snapshotVersion {
+		return nil, ErrSnapshotVersion
+	}
+	flags := header[len(snapshotMagic)+1]
+	if flags&^snapshotCompressed != 0 {
+		return nil, ErrInvalidSnapshot
+	}
+	if flags&snapshotCompressed != 0 {
+		decompressor, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, snapshotReadError(err)
+		}
+		defer decompressor.Close()
+		r = decompressor
+	}
+	reader := snapshotReader{r: bufio.NewReader(r), knownFlags: nodeKnownFlags}
+	if version == 1 {
+		reader.knownFlags = nodeKnownFlagsV1
+	}
+	path := reader.readString()
+	kind := reader.readByte()
+	if reader.err == nil && kind != recordDirectory {
+		return nil, ErrInvalidSnapshot
+	}
+	root := reader.readDirectory(path, 0)
+	if reader.err == nil && root.name != "" && !validName(root.name) {
+		return nil, ErrInvalidSnapshot
+	}
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	return root, nil
+}
+
+// snapshotWriter writes the records of a snapshot and keeps the first error
+type snapshotWriter struct {
+	w   *bufio.Writer
+	err error
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (writer *snapshotWriter) writeDirectory(dir *Directory) {
+	var flags byte
+	if dir.incomplete {
+		flags |= nodeIncomplete
+	}
+	writer.writeNode(recordDirectory, dir.name, dir.metadata, dir.digest, dir.linkTarget, flags)
+	for _, name := range sortedKeys(dir.subDirectories) {
+		writer.writeDirectory(dir.subDirectories[name])
+	}
+	for _, name := range sortedFileKeys(dir.files) {
+		file := dir.files[name]
+		writer.writeNode(recordFile, file.name, file.metadata, file.digest, "", 0)
+	}
+	for _, name := range sortedSymlinkKeys(dir.symlinks) {
+		link := dir.symlinks[name]
+		writer.writeNode(recordSymlink, link.name, link.metadata, link.digest, link.target, 0)
+	}
+	writer.writeByte(recordEnd)
+}
+
+// writeNode writes a record of kind. flags holds any flags that have no field of their own.
+func (writer *snapshotWriter) writeNode(kind byte, name string, metadata *Metadata, digest []byte, target string, flags byte) {
+	if metadata != nil {
+		flags |= nodeHasMetadata
+	}
+	if digest != nil {
+		flags |= nodeHasDigest
+	}
+	if target != "" || kind == recordSymlink {
+		flags |= nodeHasTarget
+	}
+	writer.writeByte(kind)
+	writer.writeString(name)
+	writer.writeByte(flags)
+	if metadata != nil {
+		writer.writeVarint(metadata.Size)
+		writer.writeUvarint(uint64(metadata.Mode))
+		writer.writeVarint(metadata.ModTime.Unix())
+		writer.writeUvarint(uint64(metadata.ModTime.Nanosecond()))
+		writer.writeUvarint(uint64(metadata.Uid))
+		writer.writeUvarint(uint64(metadata.Gid))
+		writer.writeUvarint(metadata.Inode)
+		writer.writeUvarint(metadata.Device)
+	}
+	if digest != nil {
+		writer.writeBytes(digest)
+	}
+	if flags&nodeHasTarget != 0 {
+		writer.writeString(target)
+	}
+}
+
+func (writer *snapshotWriter) writeByte(b byte) {
+	if writer.err == nil {
+		writer.err = writer.w.WriteByte(b)
+	}
+}
+
+func (writer *snapshotWriter) writeUvarint(value uint64) {
+	if writer.err == nil {
+		_, writer.err = writer.w.Write(writer.buf[:binary.PutUvarint(writer.buf[:], value)])
+	}
+}
+
+func (writer *snapshotWriter) writeVarint(value int64) {
+	if writer.err == nil {
+		_, writer.err = writer.w.Write(writer.buf[:binary.PutVarint(writer.buf[:], value)])
+	}
+}
+
+func (writer *snapshotWriter) writeBytes(value []byte) {
+	writer.writeUvarint(uint64(len(value)))
+	if writer.err == nil {
+		_, writer.err = writer.w.Write(value)
+	}
+}
+
+func (writer *snapshotWriter) writeString(value string) {
+	writer.writeUvarint(uint64(len(value)))
+	if writer.err == nil {
+		_, writer.err = writer.w.WriteString(value)
+	}
+}
+
+// snapshotReader reads the records of a snapshot and keeps the first error
+type snapshotReader struct {
+	r   *bufio.Reader
+	err error
+	// knownFlags are the node flags the version of the snapshot allows
+	knownFlags byte
+}
+
+// readDirectory reads the rest of a Directory record at path, whose kind has already
+// been read, along with the records of all of its descendants. depth is the number of
+// Directories above it in the snapshot.
+func (reader *snapshotReader) readDirectory(path string, depth int) *Directory {
+	name, metadata, digest, target, incomplete := reader.readNode(recordDirectory)
+	dir := NewDirectory(name, path)
+	dir.metadata, dir.digest, dir.linkTarget, dir.incomplete = metadata, digest, target, incomplete
+	if depth > maxSnapshotDepth {
+		reader.fail(ErrInvalidSnapshot)
+	}
+	names := childNames{op: "read", path: dir.FullPath()}
+	for reader.err == nil {
+		kind := reader.readByte()
+		switch kind {
+		case recordEnd:
+			return dir
+		case recordDirectory:
+			subDir := reader.readDirectory(dir.FullPath(), depth+1)
+			reader.checkName(&names, subDir.name)
+			if dir.subDirectories == nil {
+				dir.subDirectories = map[string]*Directory{}
+			}
+			dir.subDirectories[subDir.name] = subDir
+		case recordFile:
+			name, metadata, digest, _, _ := reader.readNode(kind)
+			reader.checkName(&names, name)
+			file := dir.addChildFile(name, metadata)
+			file.digest = digest
+		case recordSymlink:
+			name, metadata, digest, target, _ := reader.readNode(kind)
+			reader.checkName(&names, name)
+			link := dir.addChildSymlink(name, target, metadata)
+			link.digest = digest
+		default:
+			reader.fail(ErrInvalidSnapshot)
+		}
+	}
+	return dir
+}
+
+// checkName fails the snapshot if name is not a valid name or already taken in names
+func (reader *snapshotReader) checkName(names *childNames, name string) {
+	if reader.err == nil && names.add(name) != nil {
+		reader.fail(ErrInvalidSnapshot)
+	}
+}
+
+func (reader *snapshotReader) readNode(kind byte) (name string, metadata *Metadata, digest []byte, target string, incomplete bool) {
+	name = reader.readString()
+	flags := reader.readByte()
+	if flags&^reader.knownFlags != 0 {
+		reader.fail(ErrInvalidSnapshot)
+	}
+	incomplete = flags&nodeIncomplete != 0
+	if flags&nodeHasMetadata != 0 {
+		metadata = &Metadata{Size: reader.readVarint(), Mode: os.FileMode(reader.readUvarint())}
+		seconds, nanoseconds := reader.readVarint(), reader.readUvarint()
+		metadata.ModTime = time.Unix(seconds, int64(nanoseconds))
+		metadata.Uid = uint32(reader.readUvarint())
+		metadata.Gid = uint32(reader.readUvarint())
+		metadata.Inode = reader.readUvarint()
+		metadata.Device = reader.readUvarint()
+	}
+	if flags&nodeHasDigest != 0 {
+		digest = []byte(reader.readString())
+	}
+	if flags&nodeHasTarget != 0 {
+		target = reader.readString()
+	}
+	return
+}
+
+func (reader *snapshotReader) readByte() byte {
+	if reader.err != nil {
+		return 0
+	}
+	b, err := reader.r.ReadByte()
+	if err != nil {
+		reader.fail(err)
+	}
+	return b
+}
+
+func (reader *snapshotReader) readUvarint() uint64 {
+	if reader.err != nil {
+		return 0
+	}
+	value, err := binary.ReadUvarint(reader.r)
+	if err != nil {
+		reader.fail(err)
+	}
+	return value
+}
+
+func (reader *snapshotReader) readVarint() int64 {
+	if reader.err != nil {
+		return 0
+	}
+	value, err := binary.ReadVarint(reader.r)
+	if err != nil {
+		reader.fail(err)
+	}
+	return value
+}
+
+func (reader *snapshotReader) readString() string {
+	length := reader.readUvarint()
+	if reader.err != nil {
+		return ""
+	}
+	if length > maxSnapshotString {
+		reader.fail(ErrInvalidSnapshot)
+		return ""
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(reader.r, value); err != nil {
+		reader.fail(err)
+	}
+	return string(value)
+}
+
+// fail records err unless an error was already recorded
+func (reader *snapshotReader) fail(err error) {
+	if reader.err != nil {
+		return
+	}
+	reader.err = snapshotReadError(err)
+}
+
+// snapshotReadError returns the error to report for err, which occurred while reading
+// a snapshot. A snapshot that ends early is reported as ErrInvalidSnapshot and one that
+// cannot be decompressed as an *InvalidSnapshotError. Other errors are returned as is.
+func snapshotReadError(err error) error {
+	if _, ok := err.(flate.CorruptInputError); ok {
+		return &InvalidSnapshotError{Cause: err}
+	}
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return ErrInvalidSnapshot
+	case gzip.ErrHeader, gzip.ErrChecksum:
+		return &InvalidSnapshotError{Cause: err}
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package structure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func snapshotRoundTrip(t *testing.T, expected *Directory, options SnapshotOptions) *Directory {
+	var buffer bytes.Buffer
+	if err := WriteSnapshot(&buffer, expected, options); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ReadSnapshot(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return actual
+}
+
+func TestWriteSnapshot_RoundTrips(t *testing.T) {
+	for _, options := range []SnapshotOptions{{}, {Compress: true}} {
+		expected := encodingTree()
+		actual := snapshotRoundTrip(t, expected, options)
+		if !actual.StructureEquals(expected) {
+			t.Fatalf("snapshot with %+v did not match the original", options)
+		}
+		if !actual.Metadata().Equals(expected.Metadata()) {
+			t.Fatalf("directory metadata was incorrect. expected: %v actual: %v", expected.Metadata(), actual.Metadata())
+		}
+		file := actual.SubDirectory("sub1").File("file1")
+		expectedFile := expected.SubDirectory("sub1").File("file1")
+		if !bytes.Equal(file.Digest(), expectedFile.Digest()) || !file.Metadata().Equals(expectedFile.Metadata()) {
+			t.Fatal("file digest or metadata did not survive the round trip")
+		}
+		if actual.File("file2").Metadata() != nil || actual.File("file2").Digest() != nil {
+			t.Fatal("file without metadata or digest gained them in the round trip")
+		}
+		if target := actual.Symlink("link").Target(); target != "file2" {
+			t.Fatalf("symlink target was incorrect. expected: file2 actual: %s", target)
+		}
+	}
+}
+
+func TestWriteSnapshot_KeepsIncomplete(t *testing.T) {
+	expected := encodingTree()
+	expected.SubDirectory("sub1").incomplete = true
+	actual := snapshotRoundTrip(t, expected, SnapshotOptions{})
+	if actual.Incomplete() || !actual.SubDirectory("sub1").Incomplete() {
+		t.Fatal("incomplete flag did not survive the round trip")
+	}
+}
+
+func TestReadSnapshot_ReturnsErrorForInvalidNames(t *testing.T) {
+	for name, add := range map[string]func(dir *Directory){
+		"ParentDirectory": func(dir *Directory) { dir.addChildDirectory("..", nil) },
+		"EmptyFile":       func(dir *Directory) { dir.addChildFile("", nil) },
+		"Separator":       func(dir *Directory) { dir.addChildFile("a/../../b", nil) },
+		"Symlink":         func(dir *Directory) { dir.addChildSymlink(".", "target", nil) },
+		"Duplicate": func(dir *Directory) {
+			dir.addChildDirectory("a", nil)
+			dir.addChildFile("a", nil)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := NewDirectory("root", filepath.Join(osRoot(), "tmp"))
+			add(dir)
+			var buffer bytes.Buffer
+			if err := WriteSnapshot(&buffer, dir, SnapshotOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ReadSnapshot(&buffer); err != ErrInvalidSnapshot {
+				t.Fatalf("expected ErrInvalidSnapshot but got %v", err)
+			}
+		})
+	}
+}
+
+func TestReadSnapshot_ReturnsErrorForDeepNesting(t *testing.T) {
+	for _, test := range []struct {
+		depth int
+		valid bool
+	}{{100, true}, {maxSnapshotDepth + 10, false}} {
+		dir := NewDirectory("root", filepath.Join(osRoot(), "tmp"))
+		deepest := dir
+		for i := 0; i < test.depth; i++ {
+			deepest = deepest.addChildDirectory("d", nil)
+		}
+		var buffer bytes.Buffer
+		if err := WriteSnapshot(&buffer, dir, SnapshotOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadSnapshot(&buffer); (err == nil) != test.valid {
+			t.Fatalf("reading %d nested directories returned %v", test.depth, err)
+		}
+	}
+}
+
+func TestReadSnapshot_ReadsVersion1(t *testing.T) {
+	expected := encodingTree()
+	var buffer bytes.Buffer
+	if err := WriteSnapshot(&buffer, expected, SnapshotOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+	data[len(snapshotMagic)] = 1
+	actual, err := ReadSnapshot(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.StructureEquals(expected) {
+		t.Fatal("version 1 snapshot did not match the original")
+	}
+
+	expected.SubDirectory("sub1").incomplete = true
+	buffer.Reset()
+	if err := WriteSnapshot(&buffer, expected, SnapshotOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data = buffer.Bytes()
+	data[len(snapshotMagic)] = 1
+	if _, err := ReadSnapshot(bytes.NewReader(data)); err != ErrInvalidSnapshot {
+		t.Fatalf("expected ErrInvalidSnapshot for a version 1 snapshot with incomplete directories but got %v", err)
+	}
+	data[len(snapshotMagic)] = 0
+	if _, err := ReadSnapshot(bytes.NewReader(data)); err != ErrSnapshotVersion {
+		t.Fatalf("expected ErrSnapshotVersion for version 0 but got %v", err)
+	}
+}
+
+func TestReadSnapshot_ReturnsErrorForTruncatedSnapshot(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteSnapshot(&buffer, encodingTree(), SnapshotOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+	for _, length := range []int{0, 3, 6, len(data) / 2, len(data) - 1} {
+		if _, err := ReadSnapshot(bytes.NewReader(data[:length])); err != ErrInvalidSnapshot {
+			t.Fatalf("expected ErrInvalidSnapshot for %d bytes but got %v", length, err)
+		}
+	}
+}
+
+func TestReadSnapshot_ReturnsErrorForCorruptCompressedSnapshot(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteSnapshot(&buffer, encodingTree(), SnapshotOptions{Compress: true}); err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+	header := len(snapshotMagic) + 2
+	for _, length := range []int{header, header + 5, header + 15, len(data) / 2} {
+		if _, err := ReadSnapshot(bytes.NewReader(data[:length])); !errors.Is(err, ErrInvalidSnapshot) {
+			t.Fatalf("expected ErrInvalidSnapshot for %d bytes but got %v", length, err)
+		}
+	}
+	corrupt := append([]byte{}, data...)
+	corrupt[header] ^= 0xff
+	_, err := ReadSnapshot(bytes.NewReader(corrupt))
+	var invalid *InvalidSnapshotError
+	if !errors.Is(err, ErrInvalidSnapshot) || !errors.As(err, &invalid) || invalid.Cause != gzip.ErrHeader {
+		t.Fatalf("expected ErrInvalidSnapshot caused by gzip.ErrHeader but got %v", err)
+	}
+}
+
+func TestReadSnapshot_ReturnsErrorForNewerVersion(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteSnapshot(&buffer, encodingTree(), SnapshotOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+	data[len(snapshotMagic)] = snapshotVersion + 1
+	if _, err := ReadSnapshot(bytes.NewReader(data)); err != ErrSnapshotVersion {
+		t.Fatalf("expected ErrSnapshotVersion but got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package structure
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// specEntry is a single entry of a tree spec
+type specEntry struct {
+	line   int
+	indent int
+	name   string
+	dir    bool
+	link   bool
+	target string
+	quoted bool
+}
+
+// specIndent holds the characters that indent an entry of a tree spec, including
+// the box drawing of tree(1)
+const specIndent = " \t\u00a0│├└─"
+
+// ParseTree builds a Directory tree at path from a text spec that lists one entry per line.
+// The first entry is the root Directory and every other entry is a child of the closest
+// entry above it that is indented less. Indentation can be spaces, tabs, which count as
+// four spaces, or the box drawing of tree(1). Entries whose name ends in '/' or that have
+// children are Directories, entries written as "name -> target" are Symlinks and all
+// others are Files. A Directory reached through a symlink is written as "name/ -> target".
+// Entries of different kinds can share a name, entries of the same kind cannot.
+// Blank lines are ignored and a leading '/', as written by Print, is removed from names.
+// Names and targets can be written as double quoted Go strings, which FormatTree does
+// for those that would otherwise be read differently. Only the last element of the
+// first entry is used as the name of the root, unless it is quoted, so the first line
+// of Print can be used as is.
+// It returns an error naming the line of the first entry that cannot be parsed, which
+// is a *PathError matching ErrNameConflict for an entry that is listed twice.
+func ParseTree(spec string, path string) (*Directory, error) {
+	entries, err := parseSpecEntries(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("tree spec is empty")
+	}
+	if entries[0].link && !entries[0].dir {
+		return nil, fmt.Errorf("line %d: root of tree spec cannot be a symlink", entries[0].line)
+	}
+	rootName := entries[0].name
+	if !entries[0].quoted {
+		rootName = filepath.Base(rootName)
+	}
+	root := NewDirectory(rootName, path)
+	root.linkTarget = entries[0].target
+	// stack holds the open Directories along with their indentation and that of their children
+	stack := []*Directory{root}
+	indents := []int{entries[0].indent}
+	childIndents := []int{-1}
+	for i := 1; i < len(entries); i++ {
+		entry := entries[i]
+		for len(stack) > 1 && entry.indent <= indents[len(indents)-1] {
+			stack, indents, childIndents = stack[:len(stack)-1], indents[:len(indents)-1], childIndents[:len(childIndents)-1]
+		}
+		if entry.indent <= indents[0] {
+			return nil, fmt.Errorf("line %d: '%s' is not indented below the root", entry.line, entry.name)
+		}
+		level := len(stack) - 1
+		if childIndents[level] == -1 {
+			childIndents[level] = entry.indent
+		} else if childIndents[level] != entry.indent {
+			return nil, fmt.Errorf("line %d: indentation of '%s' does not match the entries above it", entry.line, entry.name)
+		}
+		parent := stack[level]
+		hasChildren := i+1 < len(entries) && entries[i+1].indent > entry.indent
+		nodeType := FileNode
+		switch {
+		case entry.dir || hasChildren && !entry.link:
+			nodeType = DirectoryNode
+		case entry.link:
+			nodeType = SymlinkNode
+		}
+		if childOfType(parent.childrenByName()[entry.name], nodeType) != nil {
+			return nil, newPathError("parse", filepath.Join(parent.FullPath(), entry.name), ErrNameConflict,
+				"line %d: %s '%s' is listed twice", entry.line, nodeType, entry.name)
+		}
+		switch {
+		case entry.dir || hasChildren && !entry.link:
+			subDir := parent.addChildDirectory(entry.name, nil)
+			subDir.linkTarget = entry.target
+			stack, indents, childIndents = append(stack, subDir), append(indents, entry.indent), append(childIndents, -1)
+		case hasChildren:
+			return nil, fmt.Errorf("line %d: symlink '%s' cannot have children", entry.line, entry.name)
+		case entry.link:
+			parent.addChildSymlink(entry.name, entry.target, nil)
+		default:
+			parent.addChildFile(entry.name, nil)
+		}
+	}
+	return root, nil
+}
+
+// parseSpecEntries splits a tree spec into its entries
+func parseSpecEntries(spec string) ([]specEntry, error) {
+	var entries []specEntry
+	for i, line := range strings.Split(strings.Replace(spec, "\r\n", "\n", -1), "\n") {
+		text := strings.TrimRight(line, " \t")
+		if text == "" {
+			continue
+		}
+		entry := specEntry{line: i + 1}
+		name := strings.TrimLeft(text, specIndent)
+		prefix := text[:len(text)-len(name)]
+		entry.indent = utf8.RuneCountInString(prefix) + 3*strings.Count(prefix, "\t")
+		var rest string
+		if strings.HasPrefix(name, `"`) {
+			unquoted, length, ok := specUnquote(name)
+			if !ok {
+				return nil, fmt.Errorf("line %d: %s is not a valid quoted name", entry.line, name)
+			}
+			name, rest, entry.quoted = unquoted, name[length:], true
+			if strings.HasPrefix(rest, "/") {
+				rest, entry.dir = rest[1:], true
+			}
+		} else {
+			if i := strings.Index(name, " -> "); i >= 0 {
+				name, rest = name[:i], name[i:]
+			}
+			if len(name) > 1 && strings.HasSuffix(name, "/") {
+				name, entry.dir = strings.TrimSuffix(name, "/"), true
+			}
+			if len(entries) > 0 {
+				name = strings.TrimPrefix(name, "/")
+			}
+		}
+		if rest != "" {
+			if !strings.HasPrefix(rest, " -> ") {
+				return nil, fmt.Errorf("line %d: unexpected '%s' after '%s'", entry.line, rest, name)
+			}
+			target := rest[len(" -> "):]
+			if strings.HasPrefix(target, `"`) {
+				unquoted, length, ok := specUnquote(target)
+				if !ok || length != len(target) {
+					return nil, fmt.Errorf("line %d: %s is not a valid quoted target", entry.line, target)
+				}
+				target = unquoted
+			}
+			entry.target, entry.link = target, true
+		}
+		if len(entries) > 0 && (!validName(name) || strings.ContainsRune(name, '/')) {
+			return nil, fmt.Errorf("line %d: '%s' is not a valid name", entry.line, name)
+		}
+		entry.name = name
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// specUnquote reads the double quoted Go string at the start of s. It returns the
+// string, the length of its quoted form and false if s does not start with one.
+func specUnquote(s string) (string, int, bool) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			unquoted, err := strconv.Unquote(s[:i+1])
+			return unquoted, i + 1, err == nil
+		}
+	}
+	return "", 0, false
+}
+
+// specName quotes name if ParseTree would otherwise read it differently
+func specName(name string) string {
+	if name == "" || strings.IndexAny(name, specIndent+`"/`) == 0 || strings.ContainsAny(name, "\n\r") ||
+		strings.Contains(name, "->") || strings.HasSuffix(name, " ") || strings.HasSuffix(name, "\t") {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// specTarget quotes target if ParseTree would otherwise read it differently
+func specTarget(target string) string {
+	if target == "" || strings.HasPrefix(target, `"`) || strings.ContainsAny(target, "\n\r") ||
+		strings.HasSuffix(target, " ") || strings.HasSuffix(target, "\t") {
+		return strconv.Quote(target)
+	}
+	return target
+}
+
+// FormatTree writes dir and its descendants as a spec that ParseTree reads back into
+// the same tree. Entries are sorted by name and indented by four spaces per level.
+// Names and targets that would be read differently, such as those containing " -> " or
+// starting or ending with whitespace, are written as double quoted Go strings.
+// Metadata and digests are not part of the spec.
+func FormatTree(dir *Directory) string {
+	var builder strings.Builder
+	builder.WriteString(specName(dir.name) + "/")
+	if dir.linkTarget != "" {
+		builder.WriteString(" -> " + specTarget(dir.linkTarget))
+	}
+	builder.WriteString("\n")
+	formatChildren(&builder, dir, 1)
+	return builder.String()
+}
+
+func formatChildren(builder *strings.Builder, dir *Directory, depth int) {
+	indent := strings.Repeat("    ", depth)
+	for _, node := range dir.sortedChildren() {
+		builder.WriteString(indent + specName(node.Name()))
+		switch child := node.(type) {
+		case *Directory:
+			builder.WriteString("/")
+			if child.linkTarget != "" {
+				builder.WriteString(" -> " + specTarget(child.linkTarget))
+			}
+			builder.WriteString("\n")
+			formatChildren(builder, child, depth+1)
+		case *Symlink:
+			builder.WriteString(" -> " + specTarget(child.target) + "\n")
+		default:
+			builder.WriteString("\n")
+		}
+	}
+}
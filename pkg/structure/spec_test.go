@@ -0,0 +1,160 @@
+package structure
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+var specPaths = []string{"sub1/file1", "sub1/sub2/file2", "file3", "empty/", "sub1/link -> " + filepath.Join("..", "file3")}
+
+func TestParseTree(t *testing.T) {
+	link := "link -> " + filepath.Join("..", "file3")
+	specs := map[string]string{
+		"Indented": `
+root/
+  empty/
+  file3
+  sub1/
+    file1
+    ` + link + `
+    sub2
+      file2
+`,
+		"Tabs": "root\n\tempty/\n\tfile3\n\tsub1\n\t\tfile1\n\t\t" + link + "\n\t\tsub2/\n\t\t\tfile2\n",
+		"Tree": `root
+├── empty/
+├── file3
+└── sub1
+    ├── file1
+    ├── ` + link + `
+    └── sub2
+        └── file2
+`,
+	}
+	expected := pathTree(filepath.Join(osRoot(), "tmp"), specPaths...)
+	for name, spec := range specs {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseTree(spec, filepath.Join(osRoot(), "tmp"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !actual.StructureEquals(expected) {
+				t.Fatalf("parsed tree was incorrect. expected:\n%s\nactual:\n%s", FormatTree(expected), FormatTree(actual))
+			}
+		})
+	}
+}
+
+func TestParseTree_ReadsPrintOutput(t *testing.T) {
+	expected := pathTree(filepath.Join(osRoot(), "tmp"), specPaths...)
+	printed, err := expected.Print()
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ParseTree(printed, filepath.Join(osRoot(), "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.FullPath() != expected.FullPath() || actual.SubDirectory("sub1").SubDirectory("sub2").File("file2") == nil {
+		t.Fatalf("printed tree was not parsed correctly:\n%s", printed)
+	}
+}
+
+func TestFormatTree_RoundTrips(t *testing.T) {
+	expected := pathTree(filepath.Join(osRoot(), "tmp"), specPaths...)
+	expected.SubDirectory("sub1").linkTarget = "elsewhere"
+	spec := FormatTree(expected)
+	actual, err := ParseTree(spec, expected.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.StructureEquals(expected) || actual.SubDirectory("sub1").LinkTarget() != "elsewhere" {
+		t.Fatalf("tree did not survive the round trip:\n%s", spec)
+	}
+	if again := FormatTree(actual); again != spec {
+		t.Fatalf("spec was not stable. first:\n%s\nsecond:\n%s", spec, again)
+	}
+}
+
+func TestFormatTree_RoundTripsUnusualNames(t *testing.T) {
+	names := []string{"a -> b", "ends ->", "  leading", "trailing ", "tab\t", "├── boxed", "│bar", "\u00a0space", `"quoted"`, `mid"quote`, "new\nline"}
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			root := filepath.Join(osRoot(), "tmp")
+			expected := NewDirectory(name, root)
+			if _, err := expected.AddDirectory(filepath.Join(root, name, name)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := expected.AddFile(filepath.Join(root, name, name, name)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := expected.AddSymlink(filepath.Join(root, name, "link"), name+" "); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := expected.AddSymlink(filepath.Join(root, name, "empty"), ""); err != nil {
+				t.Fatal(err)
+			}
+			spec := FormatTree(expected)
+			actual, err := ParseTree(spec, root)
+			if err != nil {
+				t.Fatalf("%v\n%s", err, spec)
+			}
+			if actual.Name() != name || !actual.StructureEquals(expected) {
+				t.Fatalf("tree did not survive the round trip:\n%s", spec)
+			}
+			if target := actual.Symlink("link").Target(); target != name+" " {
+				t.Fatalf("target was incorrect. expected: %q actual: %q", name+" ", target)
+			}
+			if target := actual.Symlink("empty").Target(); target != "" {
+				t.Fatalf("target should have been empty but was %q", target)
+			}
+		})
+	}
+}
+
+func TestFormatTree_KeepsEntriesSharingAName(t *testing.T) {
+	spec := "dir1/\n    item\n    item/\n        child\n    item -> target\n"
+	dir, err := ParseTree(spec, osRoot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.File("item") == nil || dir.SubDirectory("item") == nil || dir.Symlink("item") == nil {
+		t.Fatal("entries sharing a name were not all parsed")
+	}
+	if actual := FormatTree(dir); actual != spec {
+		t.Fatalf("spec was incorrect. expected:\n%s\nactual:\n%s", spec, actual)
+	}
+}
+
+func TestParseTree_ReturnsNameConflictForDuplicates(t *testing.T) {
+	_, err := ParseTree("dir1/\n    sub1/\n        file1\n        file1\n", osRoot())
+	var pathErr *PathError
+	if !errors.Is(err, ErrNameConflict) || !errors.As(err, &pathErr) || pathErr.Path != filepath.Join(osRoot(), "dir1", "sub1", "file1") {
+		t.Fatalf("expected a *PathError matching ErrNameConflict but got %v", err)
+	}
+}
+
+func TestParseTree_ReturnsErrors(t *testing.T) {
+	specs := map[string]string{
+		"Empty":              "\n\n",
+		"NotIndented":        "dir1/\nfile1\n",
+		"InconsistentIndent": "dir1/\n    sub1/\n        file1\n      file2\n",
+		"Duplicate":          "dir1/\n  file1\n  file1\n",
+		"DuplicateDirectory": "dir1/\n  sub1/\n  sub1\n    file1\n",
+		"SymlinkChildren":    "dir1/\n  link -> target\n    file1\n",
+		"InvalidName":        "dir1/\n  sub1/file1\n",
+		"ParentName":         "dir1/\n  ../\n",
+		"QuotedSeparator":    "dir1/\n  \"a/b\"\n",
+		"UnterminatedQuote":  "dir1/\n  \"file1\n",
+		"TextAfterQuote":     "dir1/\n  \"file1\"x\n",
+		"QuotedTarget":       "dir1/\n  link -> \"a\"b\n",
+	}
+	for name, spec := range specs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseTree(spec, osRoot()); err == nil {
+				t.Fatal("error should have been returned but was nil")
+			}
+		})
+	}
+}
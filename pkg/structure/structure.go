@@ -1,8 +1,7 @@
 package structure
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,55 +9,63 @@ import (
 
 // GetDirectoryStructure walks through a directory on disk and its descendants
 // and builds a Directory tree containing that matches the filesystem on disk
+// Symlinks are not followed and are added to the tree as Symlinks.
 // It returns the root Directory whose path is fullPath and an error if one occurs
 func GetDirectoryStructure(fullPath string, relative bool) (*Directory, error) {
+	return GetDirectoryStructureWithOptions(fullPath, ScanOptions{Relative: relative})
+}
+
+// GetDirectoryStructureWithMetadata works like GetDirectoryStructure but also
+// captures the Metadata of every File and Directory in the tree
+func GetDirectoryStructureWithMetadata(fullPath string, relative bool) (*Directory, error) {
+	return GetDirectoryStructureWithOptions(fullPath, ScanOptions{Relative: relative, Metadata: FullMetadata})
+}
+
+// GetDirectoryStructureFollowingSymlinks works like GetDirectoryStructure but follows
+// symlinks that point to directories and adds the directories they point to in their place.
+// Such Directories report the link through LinkTarget. A symlink that points to one of
+// its own ancestors is not followed and is added as a Symlink so the scan cannot loop.
+func GetDirectoryStructureFollowingSymlinks(fullPath string, relative bool) (*Directory, error) {
+	return GetDirectoryStructureWithOptions(fullPath, ScanOptions{Relative: relative, FollowSymlinks: true})
+}
+
+// GetDirectoryStructureWithOptions walks through a directory on disk and its descendants
+// and builds a Directory tree as described by options. Entries that are filtered out
+// are never added to the tree and filtered Directories are not descended into.
+// It returns the root Directory whose path is fullPath and an error if one occurs
+func GetDirectoryStructureWithOptions(fullPath string, options ScanOptions) (*Directory, error) {
+	return GetDirectoryStructureContext(context.Background(), fullPath, options)
+}
+
+// GetDirectoryStructureContext works like GetDirectoryStructureWithOptions but stops
+// scanning once ctx is done. It then returns the part of the tree that was already
+// scanned along with ctx.Err().
+// With CollectErrors the tree is returned along with a *ScanError if any path could not be read.
+func GetDirectoryStructureContext(ctx context.Context, fullPath string, options ScanOptions) (*Directory, error) {
 	d, err := os.Stat(fullPath)
 	if err != nil {
-		return nil, os.ErrNotExist
+		return nil, readError("scan", fullPath, err)
 	}
 	if !d.IsDir() {
-		return nil, errors.New(fmt.Sprintf("fullPath '%s' is not a directory", fullPath))
+		return nil, newPathError("scan", fullPath, ErrNotDirectory, "fullPath '%s' is not a directory", fullPath)
 	}
-	rootPath, rootName := filepath.Split(fullPath)
-	rootPath = filepath.Clean(rootPath)
+	rootPath, rootName := filepath.Split(filepath.Clean(fullPath))
 	var root *Directory
-	if relative {
+	if options.Relative {
 		root = NewDirectory(rootName, "")
 	} else {
 		root = NewDirectory(rootName, rootPath)
 	}
-	err = filepath.Walk(fullPath,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if path == root.FullPath() {
-				return nil
-			}
-			var addFunction func(p string) (interface{}, error)
-			var addPath string
-			if info.IsDir() {
-				if relative {
-					addFunction = func(p string) (interface{}, error) { return root.AddDirectory(p) }
-					addPath = strings.TrimPrefix(path, rootPath)
-				} else {
-					addFunction = func(p string) (interface{}, error) { return root.AddDirectory(p) }
-					addPath = path
-				}
-			} else {
-				if relative {
-					addFunction = func(p string) (interface{}, error) { return root.AddFile(p) }
-					addPath = strings.TrimPrefix(path, rootPath)
-				} else {
-					addFunction = func(p string) (interface{}, error) { return root.AddFile(p) }
-					addPath = path
-				}
-			}
-			if _, err := addFunction(addPath); err != nil {
-				return err
-			}
-			return nil
-		})
+	s, task := newScan(ctx, options, root, fullPath, d)
+	root.metadata = s.metadata(d)
+	if options.Workers > 1 {
+		err = s.scanParallel(task, options.Workers)
+	} else {
+		err = s.scanDirectory(task)
+	}
+	if err == nil {
+		err = s.failures.err()
+	}
 	return root, err
 }
 
@@ -84,29 +91,46 @@ func (dir Directory) StructureEquals(other *Directory) bool {
 			return false
 		}
 	}
+	if len(dir.symlinks) != len(other.symlinks) {
+		return false
+	}
+	for linkName, link := range dir.symlinks {
+		if otherLink, ok := other.symlinks[linkName]; !ok || !otherLink.Equals(link) {
+			return false
+		}
+	}
 	return true
 }
 
 // IsSubPath determines if fullPath is a descendant of the current Directory.
 // fullPath does not need to actually exist in the Directory. It just has
-// to be a descendant. It returns true or false accordingly.
+// to be a descendant. Paths are cleaned and compared segment by segment, so
+// "/tmp/dir1/../dir10" is not a descendant of "/tmp/dir1". The current
+// Directory itself counts as a descendant. It returns true or false accordingly.
 func (dir *Directory) IsSubPath(fullPath string) bool {
-	fullPath = filepath.Clean(fullPath)
-	fullPath = strings.TrimPrefix(fullPath, "/")
-	relPath := strings.TrimPrefix(fullPath, strings.TrimPrefix(dir.FullPath(), "/"))
-	return relPath != fullPath
+	_, ok := relativeSegments(dir.FullPath(), fullPath)
+	return ok
 }
 
+// relativePath returns fullPath relative to the current Directory, or "" if it is
+// the current Directory or not inside it
 func (dir *Directory) relativePath(fullPath string) string {
-	path := strings.TrimPrefix(fullPath, dir.FullPath())
-	path = strings.TrimPrefix(path, string(os.PathSeparator))
-	return path
+	segments, _ := relativeSegments(dir.FullPath(), fullPath)
+	return filepath.Join(segments...)
 }
 
-func (dir *Directory) createPath(pathSlice []string) (*Directory, error) {
+// createPath returns the Directory at pathSlice below the current Directory, creating the
+// Directories that do not exist. If strict is set, it returns a *ConflictError when a File
+// or Symlink is in the way.
+func (dir *Directory) createPath(pathSlice []string, strict bool) (*Directory, error) {
 	if len(pathSlice) <= 0 {
 		return dir, nil
 	}
+	if strict {
+		if _, err := (AddOptions{Conflict: KeepExisting, Strict: true}).resolve(dir, pathSlice[0], DirectoryNode); err != nil {
+			return nil, err
+		}
+	}
 	if dir.subDirectories == nil {
 		dir.subDirectories = map[string]*Directory{}
 	}
@@ -120,16 +144,20 @@ func (dir *Directory) createPath(pathSlice []string) (*Directory, error) {
 		directory = newDirectory
 		dir.subDirectories[name] = directory
 	}
-	return directory.createPath(pathSlice[1:])
+	return directory.createPath(pathSlice[1:], strict)
 }
 
 func (dir Directory) findPath(relativePath []string) (*Directory, error) {
+	if len(relativePath) == 0 {
+		return &dir, nil
+	}
 	if subDir := dir.SubDirectory(relativePath[0]); subDir != nil {
 		if len(relativePath) == 1 {
 			return subDir, nil
 		}
 		return subDir.findPath(relativePath[1:])
 	}
-	return nil, errors.New(fmt.Sprintf("directory could not be found. "+
-		"Current dir: %s Looking for: %s", dir.Path(), strings.Join(relativePath, string(os.PathSeparator))))
+	return nil, newPathError("get", filepath.Join(append([]string{dir.FullPath()}, relativePath...)...), ErrNotFound,
+		"directory could not be found. Current dir: %s Looking for: %s",
+		dir.Path(), strings.Join(relativePath, string(os.PathSeparator)))
 }
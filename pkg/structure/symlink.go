@@ -0,0 +1,108 @@
+package structure
+
+import (
+	"os"
+	"path/filepath"
+)
+
+type Symlink struct {
+	name     string
+	path     string
+	target   string
+	metadata *Metadata
+	digest   []byte
+}
+
+// Name returns the name of the Symlink
+func (link Symlink) Name() string { return link.name }
+
+// Path returns the path to the Symlink excluding the Symlink itself
+func (link Symlink) Path() string { return filepath.Clean(link.path) }
+
+// FullPath returns the full path to the Symlink including the Symlink itself
+func (link Symlink) FullPath() string { return filepath.Clean(filepath.Join(link.path, link.name)) }
+
+// Target returns the target of the Symlink exactly as it is stored in the link
+func (link Symlink) Target() string { return link.target }
+
+// Type returns SymlinkNode
+func (link Symlink) Type() NodeType { return SymlinkNode }
+
+// Metadata returns the Metadata captured for the Symlink itself
+// It returns nil if the Symlink was not scanned with metadata
+func (link Symlink) Metadata() *Metadata { return link.metadata }
+
+// SetMetadata replaces the Metadata of the Symlink
+func (link *Symlink) SetMetadata(metadata *Metadata) { link.metadata = metadata }
+
+// Digest returns the digest of the target of the Symlink
+// It returns nil if the Symlink has not been hashed
+func (link Symlink) Digest() []byte { return link.digest }
+
+// Resolve returns the path the Symlink points to. Relative targets are
+// resolved against the Path of the Symlink.
+func (link Symlink) Resolve() string {
+	if filepath.IsAbs(link.target) {
+		return filepath.Clean(link.target)
+	}
+	return filepath.Join(link.Path(), link.target)
+}
+
+// IsBroken determines if the target of the Symlink does not exist on disk.
+// The link is resolved by the operating system from its FullPath, so the tree
+// must not have been scanned as relative.
+func (link Symlink) IsBroken() bool {
+	_, err := os.Stat(link.FullPath())
+	return err != nil
+}
+
+// Equals determines if other is equivalent to the current Symlink.
+// It uses path, name and target.
+func (link Symlink) Equals(other *Symlink) bool {
+	return filepath.Clean(link.path) == filepath.Clean(other.path) &&
+		link.name == other.name &&
+		link.target == other.target
+}
+
+// NewSymlink creates a new Symlink using a name, a path and a target
+// Name is the name of of the Symlink itself.
+// Path is the path to the Symlink not including name
+// Target is the path the Symlink points to
+func NewSymlink(name string, path string, target string) Symlink {
+	return Symlink{name: name, path: path, target: target}
+}
+
+// AddSymlink creates a new Symlink pointing to target and adds it to the current Directory tree
+// The new Symlink will contain a name and a path specified by fullPath.
+// An existing Symlink at fullPath is replaced.
+// AddSymlink will return the new Symlink and an error if fullPath is not a
+// descendant of the current Directory
+func (dir *Directory) AddSymlink(fullPath string, target string) (*Symlink, error) {
+	return dir.AddSymlinkWithOptions(fullPath, target, AddOptions{})
+}
+
+// AddSymlinkWithOptions works like AddSymlink but lets options decide what happens when
+// fullPath is already taken. It returns the existing Symlink if it is kept and a
+// *ConflictError if the Symlink cannot be added.
+func (dir *Directory) AddSymlinkWithOptions(fullPath string, target string, options AddOptions) (*Symlink, error) {
+	pathSlice, name, ok := dir.resolve(fullPath)
+	if !ok {
+		return nil, newPathError("add", fullPath, ErrNotSubPath, "fullPath must be a descendant of the directory to which it is "+
+			"being added: '%s' is not a descendant of '%s'", fullPath, dir.FullPath())
+	}
+
+	parent, err := dir.parentFor(pathSlice, options)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := options.resolve(parent, name, SymlinkNode); err != nil || existing != nil {
+		existingLink, _ := existing.(*Symlink)
+		return existingLink, err
+	}
+	newSymlink := NewSymlink(name, parent.FullPath(), target)
+	if parent.symlinks == nil {
+		parent.symlinks = map[string]*Symlink{}
+	}
+	parent.symlinks[name] = &newSymlink
+	return &newSymlink, nil
+}
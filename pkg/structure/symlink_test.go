@@ -0,0 +1,95 @@
+package structure
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirectory_AddSymlink(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	link, err := dir.AddSymlink(filepath.Join(osRoot(), "tmp", "dir1", "sub1", "link"), "../target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Path() != filepath.Join(osRoot(), "tmp", "dir1", "sub1") {
+		t.Fatalf("symlink path was not set correctly. expected %s but was %s",
+			filepath.Join(osRoot(), "tmp", "dir1", "sub1"), link.Path())
+	}
+	if found := dir.SubDirectory("sub1").Symlink("link"); found == nil || !found.Equals(link) {
+		t.Fatal("symlink was not added to the tree")
+	}
+	if link.Type() != SymlinkNode {
+		t.Fatalf("symlink type was incorrect. expected: %s actual: %s", SymlinkNode, link.Type())
+	}
+}
+
+func TestDirectory_AddSymlink_ReturnsErrorIfNotSubdirectory(t *testing.T) {
+	dir := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	if _, err := dir.AddSymlink(filepath.Join(osRoot(), "other", "link"), "target"); err == nil {
+		t.Fatal("error should have been returned but was nil")
+	}
+}
+
+func TestSymlink_Resolve(t *testing.T) {
+	relative := NewSymlink("link", filepath.Join(osRoot(), "tmp", "dir1"), filepath.Join("..", "target"))
+	if expected := filepath.Join(osRoot(), "tmp", "target"); relative.Resolve() != expected {
+		t.Fatalf("relative target was not resolved correctly. expected: %s actual: %s", expected, relative.Resolve())
+	}
+	absolute := NewSymlink("link", filepath.Join(osRoot(), "tmp", "dir1"), filepath.Join(osRoot(), "target"))
+	if expected := filepath.Join(osRoot(), "target"); absolute.Resolve() != expected {
+		t.Fatalf("absolute target was not resolved correctly. expected: %s actual: %s", expected, absolute.Resolve())
+	}
+}
+
+func TestSymlink_Equals_FalseWhenDifferentTarget(t *testing.T) {
+	link1 := NewSymlink("link", filepath.Join(osRoot(), "tmp"), "target1")
+	link2 := NewSymlink("link", filepath.Join(osRoot(), "tmp"), "target2")
+	if link1.Equals(&link2) {
+		t.Fatal("symlinks were found to be equal but were not")
+	}
+}
+
+func TestDirectory_StructureEquals_ComparesSymlinks(t *testing.T) {
+	dir1 := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	dir2 := NewDirectory("dir1", filepath.Join(osRoot(), "tmp"))
+	if _, err := dir1.AddSymlink(filepath.Join(osRoot(), "tmp", "dir1", "link"), "target1"); err != nil {
+		t.Fatal(err)
+	}
+	if dir1.StructureEquals(dir2) {
+		t.Fatal("directory structures were found to be equal but were not")
+	}
+	if _, err := dir2.AddSymlink(filepath.Join(osRoot(), "tmp", "dir1", "link"), "target2"); err != nil {
+		t.Fatal(err)
+	}
+	if dir1.StructureEquals(dir2) {
+		t.Fatal("directory structures were found to be equal but were not")
+	}
+}
+
+func TestDiff_Symlinks(t *testing.T) {
+	before := NewDirectory("root", filepath.Join(osRoot(), "tmp"))
+	after := NewDirectory("root", filepath.Join(osRoot(), "tmp"))
+	for _, link := range []struct {
+		dir    *Directory
+		name   string
+		target string
+	}{
+		{before, "same", "target"},
+		{after, "same", "target"},
+		{before, "retargeted", "old"},
+		{after, "retargeted", "new"},
+		{before, "becomes-file", "target"},
+	} {
+		if _, err := link.dir.AddSymlink(filepath.Join(osRoot(), "tmp", "root", link.name), link.target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := after.AddFile(filepath.Join(osRoot(), "tmp", "root", "becomes-file")); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"type changed: becomes-file", "modified: retargeted"}
+	if actual := changeStrings(Diff(before, after)); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expected, actual)
+	}
+}
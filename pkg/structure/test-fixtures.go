@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 )
 
 func osRoot() string {
@@ -431,3 +433,83 @@ var fullPathTests = []struct {
 		}(),
 	},
 }
+
+// fixtureTree parses spec into a Directory tree at path. Fixtures are fixed, so it
+// panics if spec cannot be parsed.
+func fixtureTree(path string, spec string) *Directory {
+	dir, err := ParseTree(spec, path)
+	if err != nil {
+		panic(err)
+	}
+	return dir
+}
+
+// pathTree builds a Directory called root at path from paths relative to it, written
+// with '/'. Paths ending in '/' are Directories, "path -> target" are Symlinks and all
+// others are Files.
+func pathTree(path string, relPaths ...string) *Directory {
+	dir := NewDirectory("root", path)
+	for _, relPath := range relPaths {
+		var err error
+		fullPath := filepath.Join(path, "root", filepath.FromSlash(relPath))
+		switch parts := strings.SplitN(relPath, " -> ", 2); {
+		case len(parts) == 2:
+			_, err = dir.AddSymlink(filepath.Join(path, "root", filepath.FromSlash(parts[0])), parts[1])
+		case strings.HasSuffix(relPath, "/"):
+			_, err = dir.AddDirectory(fullPath)
+		default:
+			_, err = dir.AddFile(fullPath)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+	return dir
+}
+
+// digestTree builds a Directory called root at path with a File at every relative path
+// in digests, gives each File its value as the digest and computes the Merkle digests
+func digestTree(path string, digests map[string]string) *Directory {
+	dir := NewDirectory("root", path)
+	for relPath, digest := range digests {
+		file, err := dir.AddFile(filepath.Join(path, "root", relPath))
+		if err != nil {
+			panic(err)
+		}
+		file.SetDigest([]byte(digest))
+	}
+	dir.ComputeDirectoryHashes(nil)
+	return dir
+}
+
+// metadataTree builds a Directory called root at path with a File at every relative
+// path in metadata and gives each File a copy of its value as Metadata
+func metadataTree(path string, metadata map[string]Metadata) *Directory {
+	dir := NewDirectory("root", path)
+	for relPath, fileMetadata := range metadata {
+		file, err := dir.AddFile(filepath.Join(path, "root", relPath))
+		if err != nil {
+			panic(err)
+		}
+		fileMetadata := fileMetadata
+		file.SetMetadata(&fileMetadata)
+	}
+	return dir
+}
+
+// encodingTree is the tree with Metadata, digests and a Symlink used by the JSON and
+// snapshot tests
+func encodingTree() *Directory {
+	dir := fixtureTree(filepath.Join(osRoot(), "tmp"), `
+dir1/
+    sub1/
+        file1
+    file2
+    link -> file2
+`)
+	dir.SetMetadata(&Metadata{Size: 4096, Mode: 0755 | os.ModeDir, ModTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)})
+	file := dir.SubDirectory("sub1").File("file1")
+	file.SetMetadata(&Metadata{Size: 3, Mode: 0644, ModTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Uid: 1000})
+	file.SetDigest([]byte{0xab, 0xcd})
+	return dir
+}
@@ -0,0 +1,417 @@
+package structure
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WatchOptions controls how a Watcher keeps a Directory tree in sync with the filesystem
+type WatchOptions struct {
+	// Scan is used to read Directories again when their entries change and to scan
+	// Directories that appear while watching. It should match the options the tree
+	// was scanned with.
+	Scan ScanOptions
+	// Debounce is how long the Watcher waits for further events after an event
+	// before applying them all to the tree at once. It defaults to 100ms.
+	Debounce time.Duration
+	// MaxDelay is the longest the Watcher holds on to an event while further events keep
+	// arriving, so that a steady stream of events is still applied. It defaults to ten
+	// times Debounce and is never shorter than Debounce.
+	MaxDelay time.Duration
+	// Poll makes the Watcher Refresh the tree every PollInterval instead of asking
+	// the operating system for events. Polling is also used on platforms without
+	// native events.
+	Poll bool
+	// PollInterval is how often the tree is refreshed when polling. It defaults to 2s.
+	PollInterval time.Duration
+	// OnError is called with errors that occur while applying events, if it is set
+	OnError func(err error)
+}
+
+// Watcher keeps a Directory tree in sync with the filesystem by applying entries
+// that are created, deleted and renamed on disk to the tree in place.
+// The tree is changed from another goroutine while it is watched, so it must only
+// be read through View until the Watcher is closed.
+type Watcher struct {
+	root        *Directory
+	options     WatchOptions
+	source      eventSource
+	lock        sync.RWMutex
+	subscribers []func(changes []Change)
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stopped     sync.WaitGroup
+	closeOnce   sync.Once
+}
+
+// watchOp is the kind of a watchEvent
+type watchOp int
+
+const (
+	// opChange means the entry name inside dir was created or deleted
+	opChange watchOp = iota
+	// opMovedFrom means the entry name was moved out of dir
+	opMovedFrom
+	// opMovedTo means an entry was moved into dir as name
+	opMovedTo
+	// opOverflow means events were lost and the whole tree must be refreshed
+	opOverflow
+)
+
+// watchEvent is a single event reported by an eventSource
+type watchEvent struct {
+	dir    *Directory
+	name   string
+	op     watchOp
+	cookie uint32
+}
+
+// eventSource reports the events of the operating system for watched Directories
+type eventSource interface {
+	watch(dir *Directory) error
+	unwatch(dir *Directory)
+	events() <-chan watchEvent
+	close() error
+}
+
+// errPollingOnly is returned by newEventSource on platforms without native events
+var errPollingOnly = errors.New("structure: native filesystem events are not supported")
+
+// Watch starts keeping root in sync with the directory on disk at its FullPath.
+// root should have been scanned from disk with options.Scan and must not be relative.
+// Changes are applied in batches, which are passed to the subscribers of the Watcher
+// sorted by path and relative to root. Entries that are moved within the tree are
+// reported as Renamed or Moved, the node itself being moved along with its descendants.
+// The Watcher must be closed once it is no longer needed.
+func Watch(root *Directory, options WatchOptions) (*Watcher, error) {
+	if options.Debounce <= 0 {
+		options.Debounce = 100 * time.Millisecond
+	}
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = 10 * options.Debounce
+	} else if options.MaxDelay < options.Debounce {
+		options.MaxDelay = options.Debounce
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = 2 * time.Second
+	}
+	if info, err := os.Stat(root.FullPath()); err != nil {
+		return nil, readError("watch", root.FullPath(), err)
+	} else if !info.IsDir() {
+		return nil, newPathError("watch", root.FullPath(), ErrNotDirectory, "fullPath '%s' is not a directory", root.FullPath())
+	}
+	watcher := &Watcher{root: root, options: options}
+	watcher.ctx, watcher.cancel = context.WithCancel(context.Background())
+	if !options.Poll {
+		source, err := newEventSource()
+		if err != nil && err != errPollingOnly {
+			return nil, err
+		}
+		watcher.source = source
+	}
+	watcher.stopped.Add(1)
+	if watcher.source == nil {
+		go watcher.poll()
+		return watcher, nil
+	}
+	if err := watcher.watchTree(root); err != nil {
+		watcher.source.close()
+		return nil, err
+	}
+	go watcher.listen()
+	return watcher, nil
+}
+
+// Subscribe registers fn to be called with every batch of changes the Watcher applies.
+// fn is called from the goroutine of the Watcher after the tree has been updated,
+// so it must not block for long.
+func (watcher *Watcher) Subscribe(fn func(changes []Change)) {
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	watcher.subscribers = append(watcher.subscribers, fn)
+}
+
+// Events returns a channel that receives every batch of changes the Watcher applies.
+// The channel is closed when the Watcher is closed. It must be drained, since the
+// Watcher waits for each batch to be received before it applies the next one.
+func (watcher *Watcher) Events() <-chan []Change {
+	events := make(chan []Change, 16)
+	watcher.Subscribe(func(changes []Change) {
+		select {
+		case events <- changes:
+		case <-watcher.ctx.Done():
+		}
+	})
+	go func() {
+		watcher.stopped.Wait()
+		close(events)
+	}()
+	return events
+}
+
+// View calls fn with the root of the watched tree while no changes are applied to it
+func (watcher *Watcher) View(fn func(root *Directory)) {
+	watcher.lock.RLock()
+	defer watcher.lock.RUnlock()
+	fn(watcher.root)
+}
+
+// Close stops the Watcher. Events that have not been applied yet are discarded.
+func (watcher *Watcher) Close() error {
+	var err error
+	watcher.closeOnce.Do(func() {
+		watcher.cancel()
+		if watcher.source != nil {
+			err = watcher.source.close()
+		}
+		watcher.stopped.Wait()
+	})
+	return err
+}
+
+// poll refreshes the tree every PollInterval until the Watcher is closed
+func (watcher *Watcher) poll() {
+	defer watcher.stopped.Done()
+	ticker := time.NewTicker(watcher.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-watcher.ctx.Done():
+			return
+		case <-ticker.C:
+			watcher.lock.Lock()
+			changes, err := watcher.root.RefreshContext(watcher.ctx, watcher.options.Scan)
+			watcher.lock.Unlock()
+			watcher.handleError(err)
+			watcher.publish(changes)
+		}
+	}
+}
+
+// listen collects events from the eventSource and applies them once no further
+// event has arrived for Debounce, or once the first of them has waited for MaxDelay
+func (watcher *Watcher) listen() {
+	defer watcher.stopped.Done()
+	var pending []watchEvent
+	var flush, deadline <-chan time.Time
+	for {
+		select {
+		case <-watcher.ctx.Done():
+			return
+		case event, ok := <-watcher.source.events():
+			if !ok {
+				return
+			}
+			if pending == nil {
+				deadline = time.After(watcher.options.MaxDelay)
+			}
+			pending = append(pending, event)
+			flush = time.After(watcher.options.Debounce)
+		case <-flush:
+			watcher.publish(watcher.apply(pending))
+			pending, flush, deadline = nil, nil, nil
+		case <-deadline:
+			watcher.publish(watcher.apply(pending))
+			pending, flush, deadline = nil, nil, nil
+		}
+	}
+}
+
+// apply brings the tree up to date with a batch of events and returns the changes.
+// Moves whose source and destination are both watched are applied directly, every
+// other Directory that had events is read again.
+func (watcher *Watcher) apply(events []watchEvent) []Change {
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	var changes []Change
+	dirty := map[*Directory]bool{}
+	movedFrom := map[uint32]watchEvent{}
+	for _, event := range events {
+		switch event.op {
+		case opOverflow:
+			refreshed, err := watcher.root.RefreshContext(watcher.ctx, watcher.options.Scan)
+			watcher.handleError(err)
+			watcher.handleError(watcher.watchTree(watcher.root))
+			return append(changes, refreshed...)
+		case opMovedFrom:
+			movedFrom[event.cookie] = event
+			continue
+		case opMovedTo:
+			if from, ok := movedFrom[event.cookie]; ok {
+				delete(movedFrom, event.cookie)
+				if !watcher.move(from, event, &changes) {
+					dirty[from.dir] = true
+				}
+			}
+		}
+		dirty[event.dir] = true
+	}
+	for _, from := range movedFrom {
+		dirty[from.dir] = true
+	}
+
+	dirs := make([]*Directory, 0, len(dirty))
+	for dir := range dirty {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].FullPath() < dirs[j].FullPath() })
+	for _, dir := range dirs {
+		task, ok, err := watcher.taskFor(dir)
+		if !ok {
+			watcher.handleError(err)
+			continue
+		}
+		var dirChanges []Change
+		_, _, err = task.scanner.reread(task.scanTask, &dirChanges)
+		watcher.handleError(err)
+		watcher.track(dirChanges)
+		changes = append(changes, dirChanges...)
+	}
+	return changes
+}
+
+// move moves the node that from describes to the destination of to and reports it.
+// The Directory of to is read again afterwards, so that Metadata and filters are applied.
+// It returns false if the node is not part of the tree.
+func (watcher *Watcher) move(from watchEvent, to watchEvent, changes *[]Change) bool {
+	oldFullPath := filepath.Join(from.dir.FullPath(), from.name)
+	newFullPath := filepath.Join(to.dir.FullPath(), to.name)
+	if _, ok := from.dir.children()[from.name]; !ok || !watcher.attached(from.dir) || !watcher.attached(to.dir) {
+		return false
+	}
+	newPath := watcher.relPath(to.dir, to.name)
+	if existing, ok := to.dir.children()[to.name]; ok {
+		*changes = append(*changes, Change{Type: Removed, Path: newPath, Before: existing})
+		if subDir, ok := existing.(*Directory); ok {
+			descendantChanges(subDir, newPath, Removed, changes)
+			watcher.unwatchTree(subDir)
+		}
+		to.dir.removeChild(to.name)
+	}
+	oldPath := watcher.relPath(from.dir, from.name)
+	node, err := watcher.root.Move(oldFullPath, newFullPath)
+	if err != nil {
+		watcher.handleError(err)
+		return false
+	}
+	*changes = append(*changes, renameChange(Change{Path: oldPath, Before: node}, Change{Path: newPath, After: node}, 1))
+	return true
+}
+
+// attached determines if dir is still part of the watched tree
+func (watcher *Watcher) attached(dir *Directory) bool {
+	_, ok, _ := watcher.taskFor(dir)
+	return ok
+}
+
+// track watches the Directories that changes added to the tree and stops watching
+// those that were removed from it
+func (watcher *Watcher) track(changes []Change) {
+	for _, change := range changes {
+		if change.Type != Added && change.Type != TypeChanged && change.Type != Removed {
+			continue
+		}
+		if subDir, ok := change.Before.(*Directory); ok {
+			watcher.unwatchTree(subDir)
+		}
+		if subDir, ok := change.After.(*Directory); ok {
+			watcher.handleError(watcher.watchTree(subDir))
+		}
+	}
+}
+
+// watchTree adds dir and all of its descendant Directories to the eventSource
+func (watcher *Watcher) watchTree(dir *Directory) error {
+	if err := watcher.source.watch(dir); err != nil {
+		return err
+	}
+	for _, subDir := range dir.subDirectories {
+		if err := watcher.watchTree(subDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unwatchTree removes dir and all of its descendant Directories from the eventSource
+func (watcher *Watcher) unwatchTree(dir *Directory) {
+	watcher.source.unwatch(dir)
+	for _, subDir := range dir.subDirectories {
+		watcher.unwatchTree(subDir)
+	}
+}
+
+// watchedTask is a scanTask along with the scanner it belongs to
+type watchedTask struct {
+	scanner  scanner
+	scanTask scanTask
+}
+
+// taskFor creates the scanTask for dir by descending from the root of the tree, so that
+// it carries the depth, ancestors and gitignore rules a scan would have given it.
+// It returns false if dir is no longer part of the tree or cannot be read.
+func (watcher *Watcher) taskFor(dir *Directory) (watchedTask, bool, error) {
+	rootPath := watcher.root.FullPath()
+	info, err := os.Stat(rootPath)
+	if err != nil {
+		return watchedTask{}, false, err
+	}
+	s, task := newScan(watcher.ctx, watcher.options.Scan, watcher.root, rootPath, info)
+	segments, ok := relativeSegments(rootPath, dir.FullPath())
+	if !ok {
+		return watchedTask{}, false, nil
+	}
+	for _, name := range segments {
+		subDir, ok := task.dir.subDirectories[name]
+		if !ok {
+			return watchedTask{}, false, nil
+		}
+		subPath := filepath.Join(task.diskPath, name)
+		subInfo, err := os.Stat(subPath)
+		if err != nil {
+			return watchedTask{}, false, nil
+		}
+		if s.options.GitIgnore {
+			task.ignore = task.ignore.withIgnoreFile(task.diskPath, s.repoPath(task.relPath))
+		}
+		task = task.subTask(subDir, subPath, filepath.Join(task.relPath, name), subInfo)
+	}
+	if task.dir != dir {
+		return watchedTask{}, false, nil
+	}
+	return watchedTask{s, task}, true, nil
+}
+
+// relPath returns the path of the entry name inside dir relative to the root of the tree
+func (watcher *Watcher) relPath(dir *Directory, name string) string {
+	relPath, err := filepath.Rel(watcher.root.FullPath(), filepath.Join(dir.FullPath(), name))
+	if err != nil {
+		return name
+	}
+	return relPath
+}
+
+// publish passes changes to every subscriber if there are any
+func (watcher *Watcher) publish(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	sortChanges(changes)
+	watcher.lock.RLock()
+	subscribers := watcher.subscribers
+	watcher.lock.RUnlock()
+	for _, subscriber := range subscribers {
+		subscriber(changes)
+	}
+}
+
+func (watcher *Watcher) handleError(err error) {
+	if err != nil && err != context.Canceled && watcher.options.OnError != nil {
+		watcher.options.OnError(err)
+	}
+}
@@ -0,0 +1,137 @@
+//go:build linux
+// +build linux
+
+package structure
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotifySource is an eventSource that uses inotify
+type inotifySource struct {
+	fd    int
+	file  *os.File
+	done  chan struct{}
+	lock  sync.Mutex
+	dirs  map[int32]*Directory
+	wds   map[*Directory]int32
+	queue chan watchEvent
+}
+
+func newEventSource() (eventSource, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	source := &inotifySource{
+		fd: fd,
+		// a non-blocking descriptor is handled by the runtime poller, so that
+		// closing the file interrupts a pending read. Fd must not be called on
+		// it since that would make it blocking again.
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		done:  make(chan struct{}),
+		dirs:  map[int32]*Directory{},
+		wds:   map[*Directory]int32{},
+		queue: make(chan watchEvent, 64),
+	}
+	go source.read()
+	return source, nil
+}
+
+func (source *inotifySource) watch(dir *Directory) error {
+	wd, err := syscall.InotifyAddWatch(source.fd, dir.FullPath(), inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir.FullPath(), Err: err}
+	}
+	source.lock.Lock()
+	defer source.lock.Unlock()
+	if previous, ok := source.dirs[int32(wd)]; ok && previous != dir {
+		delete(source.wds, previous)
+	}
+	source.dirs[int32(wd)] = dir
+	source.wds[dir] = int32(wd)
+	return nil
+}
+
+func (source *inotifySource) unwatch(dir *Directory) {
+	source.lock.Lock()
+	wd, ok := source.wds[dir]
+	if ok {
+		delete(source.wds, dir)
+		delete(source.dirs, wd)
+	}
+	source.lock.Unlock()
+	if ok {
+		// the watch is already gone if the directory was deleted
+		syscall.InotifyRmWatch(source.fd, uint32(wd))
+	}
+}
+
+func (source *inotifySource) events() <-chan watchEvent {
+	return source.queue
+}
+
+func (source *inotifySource) close() error {
+	close(source.done)
+	return source.file.Close()
+}
+
+// read decodes the events read from inotify until it is closed
+func (source *inotifySource) read() {
+	defer close(source.queue)
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := source.file.Read(buffer)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buffer[nameStart:nameStart+int(raw.Len)], "\x00"))
+			offset = nameStart + int(raw.Len)
+			if event, ok := source.decode(raw, name); ok {
+				select {
+				case source.queue <- event:
+				case <-source.done:
+					return
+				}
+			}
+		}
+	}
+}
+
+// decode converts a raw inotify event into a watchEvent. It returns false for
+// events that are not of interest or belong to Directories no longer watched.
+func (source *inotifySource) decode(raw *syscall.InotifyEvent, name string) (watchEvent, bool) {
+	if raw.Mask&syscall.IN_Q_OVERFLOW != 0 {
+		return watchEvent{op: opOverflow}, true
+	}
+	source.lock.Lock()
+	defer source.lock.Unlock()
+	dir, ok := source.dirs[raw.Wd]
+	if raw.Mask&syscall.IN_IGNORED != 0 {
+		if ok && source.wds[dir] == raw.Wd {
+			delete(source.wds, dir)
+		}
+		delete(source.dirs, raw.Wd)
+		return watchEvent{}, false
+	}
+	if !ok || name == "" {
+		return watchEvent{}, false
+	}
+	event := watchEvent{dir: dir, name: name, op: opChange, cookie: raw.Cookie}
+	switch {
+	case raw.Mask&syscall.IN_MOVED_FROM != 0:
+		event.op = opMovedFrom
+	case raw.Mask&syscall.IN_MOVED_TO != 0:
+		event.op = opMovedTo
+	}
+	return event, true
+}
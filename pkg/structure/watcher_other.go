@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package structure
+
+func newEventSource() (eventSource, error) {
+	return nil, errPollingOnly
+}
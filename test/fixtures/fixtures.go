@@ -0,0 +1,31 @@
+// Package fixtures holds the helpers shared by the tests of the other packages
+package fixtures
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// CreateTree creates every path under a new temporary directory and returns it.
+// Paths ending in a separator are created as directories, all others as empty files.
+func CreateTree(t testing.TB, paths ...string) string {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		fullPath := filepath.Join(tmpDir, path)
+		if strings.HasSuffix(path, string(os.PathSeparator)) {
+			err = os.MkdirAll(fullPath, 0700)
+		} else if err = os.MkdirAll(filepath.Dir(fullPath), 0700); err == nil {
+			err = ioutil.WriteFile(fullPath, nil, 0600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tmpDir
+}
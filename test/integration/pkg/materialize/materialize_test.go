@@ -0,0 +1,199 @@
+package materialize_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/auroq/directory-structure/pkg/materialize"
+	"github.com/auroq/directory-structure/pkg/structure"
+)
+
+const spec = `
+project/
+    cmd/
+        main.go
+    README.md
+    latest -> cmd/main.go
+`
+
+func specTree(t *testing.T) *structure.Directory {
+	dir, err := structure.ParseTree(spec, filepath.Join(string(os.PathSeparator), "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func tempDir(t *testing.T) string {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpDir
+}
+
+func TestMaterialize(t *testing.T) {
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+	expected := specTree(t)
+	contents := func(file *structure.File) (io.Reader, error) {
+		return strings.NewReader("contents of " + file.Name()), nil
+	}
+
+	if _, err := materialize.Materialize(expected, tmpDir, materialize.Options{Contents: contents}); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := structure.GetDirectoryStructure(filepath.Join(tmpDir, "project"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actualSpec, expectedSpec := structure.FormatTree(actual), structure.FormatTree(expected); actualSpec != expectedSpec {
+		t.Fatalf("materialized tree was incorrect. expected:\n%s\nactual:\n%s", expectedSpec, actualSpec)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(tmpDir, "project", "cmd", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "contents of main.go" {
+		t.Fatalf("file contents were incorrect: %q", data)
+	}
+}
+
+func TestMaterialize_DryRun(t *testing.T) {
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+
+	actions, err := materialize.Materialize(specTree(t), tmpDir, materialize.Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, action := range actions {
+		actual = append(actual, action.String())
+	}
+	root := filepath.Join(tmpDir, "project")
+	expected := []string{
+		"create directory: " + root + " -rwxr-xr-x",
+		"create file: " + filepath.Join(root, "README.md") + " -rw-r--r--",
+		"create directory: " + filepath.Join(root, "cmd") + " -rwxr-xr-x",
+		"create file: " + filepath.Join(root, "cmd", "main.go") + " -rw-r--r--",
+		"create symlink: " + filepath.Join(root, "latest") + " -> cmd/main.go",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("actions were incorrect.\nexpected: %v\nactual:   %v", expected, actual)
+	}
+	if _, err := os.Lstat(root); !os.IsNotExist(err) {
+		t.Fatalf("dry run should not have created anything but found: %v", err)
+	}
+}
+
+func TestMaterialize_RefusesToOverwrite(t *testing.T) {
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+	readme := filepath.Join(tmpDir, "project", "README.md")
+	if err := os.MkdirAll(filepath.Dir(readme), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(readme, []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := materialize.Materialize(specTree(t), tmpDir, materialize.Options{}); !os.IsExist(err) {
+		t.Fatalf("expected an error for the existing file but got: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(readme); string(data) != "keep" {
+		t.Fatal("existing file was overwritten")
+	}
+
+	actions, err := materialize.Materialize(specTree(t), tmpDir, materialize.Options{Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions[1].Op != materialize.ReplaceFile {
+		t.Fatalf("expected the existing file to be replaced but got: %s", actions[1])
+	}
+	if data, _ := ioutil.ReadFile(readme); len(data) != 0 {
+		t.Fatal("existing file was not overwritten")
+	}
+}
+
+func TestMaterialize_RefusesToWriteOutsideRoot(t *testing.T) {
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+	root := filepath.Join(tmpDir, "root")
+	for _, name := range []string{"..", ".", "../escaped", filepath.Join("..", "..", "escaped")} {
+		t.Run(name, func(t *testing.T) {
+			dir := structure.NewDirectory(name, filepath.Join(string(os.PathSeparator), "src"))
+			actions, err := materialize.Materialize(dir, root, materialize.Options{})
+			if !errors.Is(err, structure.ErrInvalidName) {
+				t.Fatalf("expected ErrInvalidName but got: %v", err)
+			}
+			if len(actions) != 0 {
+				t.Fatalf("expected no actions but got: %v", actions)
+			}
+		})
+	}
+	entries, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected nothing to be written but found %s", entries[0].Name())
+	}
+}
+
+func TestMaterialize_RefusesEntriesSharingAName(t *testing.T) {
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+	dir, err := structure.ParseTree("project/\n    item\n    item/\n        file\n", filepath.Join(string(os.PathSeparator), "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err := materialize.Materialize(dir, tmpDir, materialize.Options{})
+	var conflict *structure.ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, structure.ErrNameConflict) {
+		t.Fatalf("expected a conflict for the entries sharing a name but got: %v", err)
+	}
+	if conflict.Path != filepath.Join(tmpDir, "project", "item") || conflict.Existing != structure.FileNode || conflict.Added != structure.DirectoryNode {
+		t.Fatalf("conflict was incorrect: %v", conflict)
+	}
+	if len(actions) != 1 {
+		t.Fatalf("expected only the root to be created but got: %v", actions)
+	}
+	if _, err := os.Lstat(filepath.Join(tmpDir, "project", "item")); !os.IsNotExist(err) {
+		t.Fatalf("nothing should have been written for the entries sharing a name: %v", err)
+	}
+}
+
+func TestMaterialize_AppliesModes(t *testing.T) {
+	tmpDir := tempDir(t)
+	defer os.RemoveAll(tmpDir)
+	dir := specTree(t)
+	dir.SubDirectory("cmd").SetMetadata(&structure.Metadata{Mode: os.ModeDir | 0750})
+	dir.SubDirectory("cmd").File("main.go").SetMetadata(&structure.Metadata{Mode: 0600})
+
+	if _, err := materialize.Materialize(dir, tmpDir, materialize.Options{ApplyModes: true}); err != nil {
+		t.Fatal(err)
+	}
+	modes := map[string]os.FileMode{
+		filepath.Join("project", "cmd"):            0750,
+		filepath.Join("project", "cmd", "main.go"): 0600,
+		filepath.Join("project", "README.md"):      materialize.DefaultFileMode,
+	}
+	for path, expected := range modes {
+		info, err := os.Stat(filepath.Join(tmpDir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != expected {
+			t.Fatalf("mode of %s was incorrect. expected: %s actual: %s", path, expected, info.Mode().Perm())
+		}
+	}
+}
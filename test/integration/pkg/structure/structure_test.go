@@ -1,12 +1,22 @@
 package structure
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/auroq/directory-structure/pkg/structure"
+	"github.com/auroq/directory-structure/test/fixtures"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestGetDirectoryStructure(t *testing.T) {
@@ -247,6 +257,10 @@ func TestGetDirectoryStructure_WhenFullPathIsNotADirectory(t *testing.T) {
 	if expected := fmt.Sprintf("fullPath '%s' is not a directory", file.Name()); err.Error() != expected {
 		t.Fatalf("error message was incorrect. expected: '%s' actual: '%s'", expected, err.Error())
 	}
+	var pathErr *structure.PathError
+	if !errors.Is(err, structure.ErrNotDirectory) || !errors.As(err, &pathErr) || pathErr.Path != file.Name() {
+		t.Fatalf("expected a *PathError for '%s' matching ErrNotDirectory but got %#v", file.Name(), err)
+	}
 }
 
 func TestGetDirectoryStructure_WhenFullPathDoesNotExist(t *testing.T) {
@@ -260,7 +274,913 @@ func TestGetDirectoryStructure_WhenFullPathDoesNotExist(t *testing.T) {
 	if err == nil {
 		t.Fatal("an error was expected but err was nil")
 	}
-	if !os.IsNotExist(err) {
+	if !errors.Is(err, os.ErrNotExist) {
 		t.Fatalf("error was incorrect. expected: os.NotExists actual: '%s'", err.Error())
 	}
+	var pathErr *structure.PathError
+	if !errors.Is(err, structure.ErrNotFound) || !errors.As(err, &pathErr) || pathErr.Path != path {
+		t.Fatalf("expected a *PathError for '%s' matching ErrNotFound but got %#v", path, err)
+	}
+}
+
+func TestGetDirectoryStructure_WhenFullPathCannotBeRead(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	tmpDir := fixtures.CreateTree(t, "locked"+string(os.PathSeparator))
+	if err := os.Chmod(tmpDir, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(tmpDir, 0700)
+
+	_, err := structure.GetDirectoryStructure(filepath.Join(tmpDir, "locked"), false)
+	if !errors.Is(err, os.ErrPermission) || !errors.Is(err, structure.ErrUnreadable) || errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a permission error but got %v", err)
+	}
+}
+
+func TestGetDirectoryStructureWithMetadata(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	err = os.Mkdir(filepath.Join(tmpDir, "dir1"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "dir1", "file"), []byte("hello"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := structure.GetDirectoryStructureWithMetadata(tmpDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.Metadata() == nil || !actual.Metadata().Mode.IsDir() {
+		t.Fatal("root directory metadata was not captured")
+	}
+	dir1, err := actual.GetDirectory(filepath.Join(tmpDir, "dir1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir1.Metadata() == nil || dir1.Metadata().Mode.Perm() != 0700 {
+		t.Fatal("directory metadata was not captured")
+	}
+	file, err := actual.GetFile(filepath.Join(tmpDir, "dir1", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(tmpDir, "dir1", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata := file.Metadata(); metadata == nil {
+		t.Fatal("file metadata was not captured")
+	} else if !metadata.Equals(structure.NewMetadata(info)) {
+		t.Fatalf("file metadata was incorrect. expected: %+v actual: %+v", structure.NewMetadata(info), metadata)
+	} else if metadata.Size != 5 {
+		t.Fatalf("file size was incorrect. expected: 5 actual: %d", metadata.Size)
+	}
+}
+
+func TestGetDirectoryStructure_WithoutMetadata(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "file"), []byte("hello"), 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := structure.GetDirectoryStructure(tmpDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.Metadata() != nil || actual.File("file").Metadata() != nil {
+		t.Fatal("metadata was captured but should not have been")
+	}
+}
+
+func TestDirectory_ComputeHashes(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	err = os.MkdirAll(filepath.Join(tmpDir, "dir1", "sub1"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "dir1", "sub1", "file"), []byte("hello"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := structure.GetDirectoryStructure(tmpDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := actual.ComputeHashes(nil); err != nil {
+		t.Fatal(err)
+	}
+	file, err := actual.GetFile(filepath.Join(tmpDir, "dir1", "sub1", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := sha256.Sum256([]byte("hello")); !bytes.Equal(file.Digest(), expected[:]) {
+		t.Fatalf("file digest was incorrect. expected: %x actual: %x", expected, file.Digest())
+	}
+	if actual.Digest() == nil {
+		t.Fatal("directory digest was not computed")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "dir1", "sub1", "file"), []byte("world"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := structure.GetDirectoryStructure(tmpDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changed.ComputeHashes(nil); err != nil {
+		t.Fatal(err)
+	}
+	if actual.ContentEquals(changed) {
+		t.Fatal("directory digests matched after a file changed")
+	}
+}
+
+func TestGetDirectoryStructure_RecordsSymlinks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	err = os.MkdirAll(filepath.Join(tmpDir, "dir1", "sub1"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("dir1", filepath.Join(tmpDir, "dirlink"))
+	if err != nil {
+		t.Skip("symlinks are not supported: ", err)
+	}
+	err = os.Symlink("missing", filepath.Join(tmpDir, "broken"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := structure.GetDirectoryStructure(tmpDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.SubDirectory("dirlink") != nil || actual.File("dirlink") != nil {
+		t.Fatal("symlink was added as a directory or file")
+	}
+	link := actual.Symlink("dirlink")
+	if link == nil {
+		t.Fatal("symlink was not added")
+	}
+	if link.Target() != "dir1" {
+		t.Fatalf("symlink target was incorrect. expected: dir1 actual: %s", link.Target())
+	}
+	if link.IsBroken() {
+		t.Fatal("symlink was found to be broken but was not")
+	}
+	if broken := actual.Symlink("broken"); broken == nil || !broken.IsBroken() {
+		t.Fatal("symlink was not found to be broken but was")
+	}
+}
+
+func TestGetDirectoryStructureFollowingSymlinks(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	err = os.MkdirAll(filepath.Join(tmpDir, "dir1", "sub1"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "dir1", "sub1", "file"), nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(filepath.Join("..", "dir1"), filepath.Join(tmpDir, "dir1", "sub1", "loop"))
+	if err != nil {
+		t.Skip("symlinks are not supported: ", err)
+	}
+	err = os.Symlink("dir1", filepath.Join(tmpDir, "dirlink"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := structure.GetDirectoryStructureFollowingSymlinks(tmpDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	followed := actual.SubDirectory("dirlink")
+	if followed == nil {
+		t.Fatal("symlink to directory was not followed")
+	}
+	if !followed.IsSymlink() || followed.LinkTarget() != "dir1" {
+		t.Fatalf("followed directory did not record its link target: '%s'", followed.LinkTarget())
+	}
+	if _, err := actual.GetFile(filepath.Join(tmpDir, "dirlink", "sub1", "file")); err != nil {
+		t.Fatal(err)
+	}
+	if loop := followed.SubDirectory("sub1").Symlink("loop"); loop == nil {
+		t.Fatal("symlink loop was not recorded as a symlink")
+	}
+	if loop := actual.SubDirectory("dir1").SubDirectory("sub1").Symlink("loop"); loop == nil {
+		t.Fatal("symlink loop was not recorded as a symlink")
+	}
+}
+
+func TestGetDirectoryStructureWithOptions_MaxDepth(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t, filepath.Join("dir1", "sub1", "file"), "file")
+	defer os.RemoveAll(tmpDir)
+
+	actual, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{MaxDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub1, err := actual.GetDirectory(filepath.Join(tmpDir, "dir1", "sub1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sub1.Files()) != 0 {
+		t.Fatal("entries deeper than MaxDepth were scanned")
+	}
+	if actual.File("file") == nil {
+		t.Fatal("entries within MaxDepth were not scanned")
+	}
+}
+
+func TestGetDirectoryStructureWithOptions_Filters(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t,
+		filepath.Join("src", "main.go"),
+		filepath.Join("src", "main_test.go"),
+		filepath.Join("vendor", "lib", "lib.go"),
+		filepath.Join(".git", "HEAD"),
+		".hidden",
+	)
+	defer os.RemoveAll(tmpDir)
+
+	var visited []string
+	actual, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{
+		IncludeFile: func(path string, info os.FileInfo) bool { return filepath.Ext(path) == ".go" },
+		ExcludeFile: func(path string, info os.FileInfo) bool { return strings.HasSuffix(path, "_test.go") },
+		ExcludeDirectory: func(path string, info os.FileInfo) bool {
+			visited = append(visited, path)
+			return path == "vendor"
+		},
+		Hidden: structure.ExcludeHidden,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := structure.NewDirectory(filepath.Base(tmpDir), filepath.Dir(tmpDir))
+	if _, err := expected.AddFile(filepath.Join(tmpDir, "src", "main.go")); err != nil {
+		t.Fatal(err)
+	}
+	if !actual.StructureEquals(expected) {
+		t.Fatal("directory structures did not match")
+	}
+	for _, path := range visited {
+		if strings.HasPrefix(path, "vendor"+string(os.PathSeparator)) || strings.HasPrefix(path, ".git") {
+			t.Fatalf("excluded directory was descended into: %s", path)
+		}
+	}
+}
+
+func TestGetDirectoryStructureWithOptions_BasicMetadata(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t, "file")
+	defer os.RemoveAll(tmpDir)
+
+	actual, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{Metadata: structure.BasicMetadata})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := actual.File("file").Metadata()
+	if metadata == nil || metadata.ModTime.IsZero() {
+		t.Fatal("basic metadata was not captured")
+	}
+	if metadata.Inode != 0 {
+		t.Fatal("full metadata was captured but only basic metadata was requested")
+	}
+}
+
+func TestGetDirectoryStructureWithOptions_AbortOnError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	tmpDir := fixtures.CreateTree(t, filepath.Join("locked", "file"), filepath.Join("open", "file"))
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(filepath.Join(tmpDir, "locked"), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(filepath.Join(tmpDir, "locked"), 0700)
+
+	for _, workers := range []int{0, 4} {
+		_, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{Workers: workers})
+		var pathErr *structure.PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != filepath.Join(tmpDir, "locked") {
+			t.Fatalf("expected a *PathError for the locked directory but got %v", err)
+		}
+		if !errors.Is(err, structure.ErrUnreadable) || !errors.Is(err, os.ErrPermission) {
+			t.Fatalf("expected an error matching ErrUnreadable and os.ErrPermission but got %v", err)
+		}
+	}
+}
+
+func TestGetDirectoryStructureWithOptions_SkipOnError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	tmpDir := fixtures.CreateTree(t, filepath.Join("locked", "file"), filepath.Join("open", "file"))
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(filepath.Join(tmpDir, "locked"), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(filepath.Join(tmpDir, "locked"), 0700)
+
+	if _, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{}); err == nil {
+		t.Fatal("an error was expected but err was nil")
+	}
+	actual, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{Errors: structure.SkipOnError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := actual.GetFile(filepath.Join(tmpDir, "open", "file")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetDirectoryStructureWithOptions_CollectErrors(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	tmpDir := fixtures.CreateTree(t,
+		filepath.Join("locked", "file"),
+		filepath.Join("open", "locked", "file"),
+		filepath.Join("open", "file"),
+	)
+	defer os.RemoveAll(tmpDir)
+	for _, path := range []string{filepath.Join(tmpDir, "locked"), filepath.Join(tmpDir, "open", "locked")} {
+		if err := os.Chmod(path, 0); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(path, 0700)
+	}
+
+	for _, workers := range []int{0, 4} {
+		options := structure.ScanOptions{Errors: structure.CollectErrors, Workers: workers}
+		actual, err := structure.GetDirectoryStructureWithOptions(tmpDir, options)
+		var scanErr *structure.ScanError
+		if !errors.As(err, &scanErr) {
+			t.Fatalf("expected a *ScanError but got %v", err)
+		}
+		if len(scanErr.Failures) != 2 ||
+			scanErr.Failures[0].Path != filepath.Join(tmpDir, "locked") ||
+			scanErr.Failures[1].Path != filepath.Join(tmpDir, "open", "locked") {
+			t.Fatalf("failures were incorrect: %v", err)
+		}
+		if !errors.Is(scanErr.Failures[0], structure.ErrUnreadable) || !errors.Is(scanErr.Failures[0], os.ErrPermission) {
+			t.Fatalf("failure did not carry its cause: %v", scanErr.Failures[0])
+		}
+		if _, err := actual.GetFile(filepath.Join(tmpDir, "open", "file")); err != nil {
+			t.Fatal(err)
+		}
+		if !actual.SubDirectory("locked").Incomplete() || !actual.SubDirectory("open").SubDirectory("locked").Incomplete() {
+			t.Fatal("unreadable directories were not marked as incomplete")
+		}
+		if actual.Incomplete() || actual.SubDirectory("open").Incomplete() {
+			t.Fatal("readable directories were marked as incomplete")
+		}
+	}
+}
+
+func TestDirectory_Refresh_CollectErrors(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t, filepath.Join("dir1", "sub1", "file1"), filepath.Join("dir1", "file2"))
+	defer os.RemoveAll(tmpDir)
+	options := structure.ScanOptions{Metadata: structure.BasicMetadata, Errors: structure.CollectErrors}
+	actual, err := structure.GetDirectoryStructureWithOptions(tmpDir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sub1 vanishes without dir1 appearing to change
+	dir1 := filepath.Join(tmpDir, "dir1")
+	info, err := os.Stat(dir1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir1, "sub1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(dir1, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := actual.Refresh(options)
+	var scanErr *structure.ScanError
+	if !errors.As(err, &scanErr) || len(scanErr.Failures) != 1 || !errors.Is(scanErr.Failures[0], structure.ErrNotFound) {
+		t.Fatalf("expected a *ScanError for the vanished directory but got %v", err)
+	}
+	if scanErr.Failures[0].Path != filepath.Join(dir1, "sub1") {
+		t.Fatalf("failure path was incorrect: %s", scanErr.Failures[0].Path)
+	}
+	if len(changes) != 0 || !actual.SubDirectory("dir1").Incomplete() {
+		t.Fatalf("expected dir1 to be marked as incomplete without changes but got %v", changes)
+	}
+
+	changes, err = actual.Refresh(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Type != structure.Removed || changes[0].Path != filepath.Join("dir1", "sub1") {
+		t.Fatalf("incomplete directory was not read again: %v", changes)
+	}
+	if actual.SubDirectory("dir1").Incomplete() {
+		t.Fatal("directory that was read again is still marked as incomplete")
+	}
+}
+
+func TestGetDirectoryStructureWithOptions_GitIgnore(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t,
+		filepath.Join(".git", "info")+string(os.PathSeparator),
+		filepath.Join("node_modules", "lib", "index.js"),
+		filepath.Join("src", "main.go"),
+		filepath.Join("src", "gen.pb.go"),
+		filepath.Join("src", "keep.pb.go"),
+		filepath.Join("src", "build", "out"),
+		filepath.Join("build", "out"),
+		"secret.env",
+	)
+	defer os.RemoveAll(tmpDir)
+	for path, contents := range map[string]string{
+		".gitignore":                             "node_modules/\n/build/\n*.pb.go\n",
+		filepath.Join("src", ".gitignore"):       "!keep.pb.go\n",
+		filepath.Join(".git", "info", "exclude"): "*.env\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, path), []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var visited []string
+	actual, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{
+		GitIgnore: true,
+		IncludeDirectory: func(path string, info os.FileInfo) bool {
+			visited = append(visited, path)
+			return true
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := structure.NewDirectory(filepath.Base(tmpDir), filepath.Dir(tmpDir))
+	for _, path := range []string{
+		".gitignore",
+		filepath.Join("src", ".gitignore"),
+		filepath.Join("src", "main.go"),
+		filepath.Join("src", "keep.pb.go"),
+		filepath.Join("src", "build", "out"),
+	} {
+		if _, err := expected.AddFile(filepath.Join(tmpDir, path)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !actual.StructureEquals(expected) {
+		output, _ := actual.Print()
+		t.Fatalf("directory structures did not match:\n%s", output)
+	}
+	for _, path := range visited {
+		if strings.HasPrefix(path, "node_modules") || strings.HasPrefix(path, ".git") {
+			t.Fatalf("ignored directory was descended into: %s", path)
+		}
+	}
+
+	sub, err := structure.GetDirectoryStructureWithOptions(filepath.Join(tmpDir, "src"), structure.ScanOptions{GitIgnore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.File("gen.pb.go") != nil || sub.File("keep.pb.go") == nil {
+		t.Fatal("gitignore files of parent directories were not honoured")
+	}
+}
+
+// createWideTree creates a tree with width directories at each of depth levels,
+// each of which contains width files
+func createWideTree(t testing.TB, width int, depth int) string {
+	var paths []string
+	var addLevel func(prefix string, level int)
+	addLevel = func(prefix string, level int) {
+		for i := 0; i < width; i++ {
+			paths = append(paths, filepath.Join(prefix, fmt.Sprintf("file%d", i)))
+			if level < depth {
+				addLevel(filepath.Join(prefix, fmt.Sprintf("dir%d", i)), level+1)
+			}
+		}
+	}
+	addLevel("", 1)
+	return fixtures.CreateTree(t, paths...)
+}
+
+func TestGetDirectoryStructureWithOptions_WorkersMatchesSerial(t *testing.T) {
+	tmpDir := createWideTree(t, 6, 3)
+	defer os.RemoveAll(tmpDir)
+	err := os.Symlink(filepath.Join("..", ".."), filepath.Join(tmpDir, "dir1", "dir2", "loop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, options := range []structure.ScanOptions{
+		{},
+		{FollowSymlinks: true, Metadata: structure.FullMetadata},
+		{MaxDepth: 2},
+	} {
+		serial, err := structure.GetDirectoryStructureWithOptions(tmpDir, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		options.Workers = 8
+		parallel, err := structure.GetDirectoryStructureWithOptions(tmpDir, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !parallel.StructureEquals(serial) {
+			t.Fatalf("parallel scan did not match serial scan with options %+v", options)
+		}
+		if changes := structure.Diff(serial, parallel); len(changes) != 0 {
+			t.Fatalf("parallel scan did not match serial scan: %v", changes)
+		}
+	}
+}
+
+func TestGetDirectoryStructureWithOptions_WorkersReturnsError(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	tmpDir := fixtures.CreateTree(t, filepath.Join("locked", "file"), filepath.Join("open", "file"))
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(filepath.Join(tmpDir, "locked"), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(filepath.Join(tmpDir, "locked"), 0700)
+
+	if _, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{Workers: 4}); err == nil {
+		t.Fatal("an error was expected but err was nil")
+	}
+}
+
+func benchmarkGetDirectoryStructure(b *testing.B, workers int) {
+	tmpDir := createWideTree(b, 12, 3)
+	defer os.RemoveAll(tmpDir)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{Workers: workers}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetDirectoryStructure_Serial(b *testing.B) { benchmarkGetDirectoryStructure(b, 1) }
+
+func BenchmarkGetDirectoryStructure_Workers4(b *testing.B) { benchmarkGetDirectoryStructure(b, 4) }
+
+func BenchmarkGetDirectoryStructure_Workers16(b *testing.B) { benchmarkGetDirectoryStructure(b, 16) }
+
+func TestGetDirectoryStructureContext_ReturnsPartialTreeWhenCancelled(t *testing.T) {
+	tmpDir := createWideTree(t, 4, 3)
+	defer os.RemoveAll(tmpDir)
+
+	for _, workers := range []int{1, 4} {
+		ctx, cancel := context.WithCancel(context.Background())
+		var mu sync.Mutex
+		seen := 0
+		actual, err := structure.GetDirectoryStructureContext(ctx, tmpDir, structure.ScanOptions{
+			Workers: workers,
+			IncludeDirectory: func(path string, info os.FileInfo) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				if seen++; seen == 2 {
+					cancel()
+				}
+				return true
+			},
+		})
+		if err != context.Canceled {
+			t.Fatalf("error was incorrect. expected: %v actual: %v", context.Canceled, err)
+		}
+		if actual == nil || len(actual.SubDirectories()) == 0 {
+			t.Fatal("the partial tree was not returned")
+		}
+		complete, err := structure.GetDirectoryStructure(tmpDir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual.StructureEquals(complete) {
+			t.Fatal("the scan did not stop when the context was cancelled")
+		}
+	}
+}
+
+func TestDirectory_Refresh(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t,
+		filepath.Join("dir1", "file1"),
+		filepath.Join("dir1", "sub1", "file2"),
+		filepath.Join("dir2", "file3"),
+		filepath.Join("dir3", "file4"),
+	)
+	defer os.RemoveAll(tmpDir)
+	options := structure.ScanOptions{Metadata: structure.BasicMetadata}
+	actual, err := structure.GetDirectoryStructureWithOptions(tmpDir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unchanged := actual.SubDirectory("dir2").File("file3")
+
+	if err := os.Remove(filepath.Join(tmpDir, "dir1", "file1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "dir1", "sub1", "new", "deeper"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(tmpDir, "dir3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "dir3"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := actual.Refresh(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actualChanges []string
+	for _, change := range changes {
+		actualChanges = append(actualChanges, change.String())
+	}
+	expectedChanges := []string{
+		"removed: " + filepath.Join("dir1", "file1"),
+		"added: " + filepath.Join("dir1", "sub1", "new"),
+		"added: " + filepath.Join("dir1", "sub1", "new", "deeper"),
+		"type changed: dir3",
+	}
+	if !reflect.DeepEqual(actualChanges, expectedChanges) {
+		t.Fatalf("changes were incorrect. expected: %v actual: %v", expectedChanges, actualChanges)
+	}
+	expected, err := structure.GetDirectoryStructureWithOptions(tmpDir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.StructureEquals(expected) {
+		t.Fatal("refreshed directory structure did not match a new scan")
+	}
+	if actual.SubDirectory("dir2").File("file3") != unchanged {
+		t.Fatal("an unchanged file was replaced")
+	}
+
+	changes, err = actual.Refresh(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("no changes were expected but found: %v", changes)
+	}
+}
+
+func TestDirectory_Refresh_ClearsDigestsOfChangedFiles(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t,
+		filepath.Join("dir1", "file1"),
+		filepath.Join("dir1", "file2"),
+	)
+	defer os.RemoveAll(tmpDir)
+	options := structure.ScanOptions{Metadata: structure.BasicMetadata}
+	actual, err := structure.GetDirectoryStructureWithOptions(tmpDir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := actual.ComputeHashes(nil); err != nil {
+		t.Fatal(err)
+	}
+	unchanged := actual.SubDirectory("dir1").File("file2").Digest()
+
+	changedPath := filepath.Join(tmpDir, "dir1", "file1")
+	if err := ioutil.WriteFile(changedPath, []byte("new contents"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(changedPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "dir1", "file3"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := actual.Refresh(options); err != nil {
+		t.Fatal(err)
+	}
+	if digest := actual.SubDirectory("dir1").File("file1").Digest(); digest != nil {
+		t.Fatalf("digest of the changed file should have been cleared but was %x", digest)
+	}
+	if !bytes.Equal(actual.SubDirectory("dir1").File("file2").Digest(), unchanged) {
+		t.Fatal("digest of an unchanged file was not kept")
+	}
+	if actual.Digest() != nil {
+		t.Fatal("digest of the root should have been cleared")
+	}
+	if err := actual.ComputeHashes(nil); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := structure.GetDirectoryStructureWithOptions(tmpDir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expected.ComputeHashes(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !actual.ContentEquals(expected) {
+		t.Fatalf("refreshed directory did not match a new scan: %v", structure.Diff(actual, expected))
+	}
+}
+
+// waitForChanges reads batches of changes from events until every change in expected
+// has been seen and returns all of the changes that were seen
+func waitForChanges(t *testing.T, events <-chan []structure.Change, expected ...string) []string {
+	var seen []string
+	missing := map[string]bool{}
+	for _, change := range expected {
+		missing[change] = true
+	}
+	timeout := time.After(5 * time.Second)
+	for len(missing) > 0 {
+		select {
+		case changes := <-events:
+			for _, change := range changes {
+				seen = append(seen, change.String())
+				delete(missing, change.String())
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for changes. expected: %v seen: %v", expected, seen)
+		}
+	}
+	return seen
+}
+
+func TestWatch(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t,
+		filepath.Join("dir1", "file1"),
+		filepath.Join("dir1", "file2"),
+		filepath.Join("dir2", "sub1", "file3"),
+		filepath.Join("dir3")+string(os.PathSeparator),
+	)
+	defer os.RemoveAll(tmpDir)
+	root, err := structure.GetDirectoryStructure(tmpDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := root.SubDirectory("dir2").SubDirectory("sub1")
+	watcher, err := structure.Watch(root, structure.WatchOptions{Debounce: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	events := watcher.Events()
+
+	if err := os.Rename(filepath.Join(tmpDir, "dir1", "file1"), filepath.Join(tmpDir, "dir1", "renamed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(tmpDir, "dir2", "sub1"), filepath.Join(tmpDir, "dir3", "sub1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "dir1", "file2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "dir4", "new"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	waitForChanges(t, events,
+		"renamed: "+filepath.Join("dir1", "file1")+" -> "+filepath.Join("dir1", "renamed"),
+		"moved: "+filepath.Join("dir2", "sub1")+" -> "+filepath.Join("dir3", "sub1"),
+		"removed: "+filepath.Join("dir1", "file2"),
+		"added: dir4",
+	)
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "dir4", "new", "file4"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitForChanges(t, events, "added: "+filepath.Join("dir4", "new", "file4"))
+
+	expected, err := structure.GetDirectoryStructure(tmpDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.View(func(actual *structure.Directory) {
+		if !actual.StructureEquals(expected) {
+			t.Fatal("watched directory structure did not match a new scan")
+		}
+		if actual.SubDirectory("dir3").SubDirectory("sub1") != moved {
+			t.Fatal("a moved directory was replaced")
+		}
+		if expectedPath := filepath.Join(tmpDir, "dir3", "sub1"); moved.FullPath() != expectedPath {
+			t.Fatalf("moved directory path was incorrect. expected: %s actual: %s", expectedPath, moved.FullPath())
+		}
+	})
+}
+
+func TestWatch_MaxDelay(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t, "dir1"+string(os.PathSeparator))
+	defer os.RemoveAll(tmpDir)
+	root, err := structure.GetDirectoryStructure(tmpDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher, err := structure.Watch(root, structure.WatchOptions{Debounce: 200 * time.Millisecond, MaxDelay: 300 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	events := watcher.Events()
+
+	// events keep arriving faster than Debounce, so only MaxDelay can flush them
+	stop := time.After(3 * time.Second)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case changes := <-events:
+			if len(changes) == 0 {
+				t.Fatal("an empty batch of changes was published")
+			}
+			return
+		case <-ticker.C:
+			if err := ioutil.WriteFile(filepath.Join(tmpDir, "dir1", fmt.Sprintf("file%d", i)), nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+		case <-stop:
+			t.Fatal("no changes were published while events kept arriving")
+		}
+	}
+}
+
+func TestWatch_Poll(t *testing.T) {
+	tmpDir := fixtures.CreateTree(t, filepath.Join("dir1", "file1"))
+	defer os.RemoveAll(tmpDir)
+	options := structure.ScanOptions{Metadata: structure.BasicMetadata}
+	root, err := structure.GetDirectoryStructureWithOptions(tmpDir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher, err := structure.Watch(root, structure.WatchOptions{Scan: options, Poll: true, PollInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	events := watcher.Events()
+
+	if err := os.Remove(filepath.Join(tmpDir, "dir1", "file1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "file2"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitForChanges(t, events, "removed: "+filepath.Join("dir1", "file1"), "added: file2")
+	if err := watcher.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("events channel was not closed")
+	}
+}
+
+func TestWriteSnapshot_SmallerThanJSON(t *testing.T) {
+	tmpDir := createWideTree(t, 4, 3)
+	defer os.RemoveAll(tmpDir)
+	expected, err := structure.GetDirectoryStructureWithOptions(tmpDir, structure.ScanOptions{Metadata: structure.FullMetadata})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot bytes.Buffer
+	if err := structure.WriteSnapshot(&snapshot, expected, structure.SnapshotOptions{Compress: true}); err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Len() >= len(encoded) {
+		t.Fatalf("snapshot was not smaller than json. snapshot: %d json: %d", snapshot.Len(), len(encoded))
+	}
+	actual, err := structure.ReadSnapshot(&snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.StructureEquals(expected) {
+		t.Fatal("snapshot directory structure did not match the scan")
+	}
 }